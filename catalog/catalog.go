@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// URL is the url of the official mosmix station catalog
+const URL = "https://www.dwd.de/DE/leistungen/opendata/help/stationen/mosmix_stationskatalog.cfg?view=nasPublication&nn=16102"
+
+type column struct {
+	start, end int
+}
+
+// Parse parses the fixed width mosmix station catalog. The columns are taken
+// from the line of dashes below the header, like
+//
+//	ID    ICAO NAME                 LAT    LON     ELEV
+//	----- ---- -------------------- -----  ------- -----
+//	01001 ENJA JAN MAYEN             70.56   -8.40    10
+func Parse(r io.Reader) ([]mosmix.Station, error) {
+	scanner := bufio.NewScanner(r)
+
+	var columns []column
+	var stations []mosmix.Station
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			decoded, err := charmap.ISO8859_1.NewDecoder().String(line)
+			if err != nil {
+				return nil, err
+			}
+			line = decoded
+		}
+
+		if columns == nil {
+			if strings.HasPrefix(line, "-----") {
+				columns = parseColumns(line)
+				if len(columns) != 6 {
+					return nil, fmt.Errorf("line %d: expected 6 columns, found %d", lineNumber, len(columns))
+				}
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		station, err := parseStation([]rune(line), columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+		stations = append(stations, station)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if columns == nil {
+		return nil, errors.New("no column header found in station catalog")
+	}
+
+	return stations, nil
+}
+
+func parseColumns(line string) []column {
+	var columns []column
+	start := -1
+	for i, c := range line {
+		if c == '-' && start < 0 {
+			start = i
+		} else if c != '-' && start >= 0 {
+			columns = append(columns, column{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		columns = append(columns, column{start, len(line)})
+	}
+
+	// values may be wider than the dashes, so every column reaches up to the next
+	for i := 0; i < len(columns)-1; i++ {
+		columns[i].end = columns[i+1].start
+	}
+	columns[len(columns)-1].end = -1
+
+	return columns
+}
+
+// field returns the trimmed value of the column, the line is split into runes
+// as the names may contain umlauts
+func field(line []rune, c column) string {
+	if c.start >= len(line) {
+		return ""
+	}
+	if c.end < 0 || c.end > len(line) {
+		return strings.TrimSpace(string(line[c.start:]))
+	}
+	return strings.TrimSpace(string(line[c.start:c.end]))
+}
+
+func parseStation(line []rune, columns []column) (mosmix.Station, error) {
+	station := mosmix.Station{
+		ID:   field(line, columns[0]),
+		ICAO: field(line, columns[1]),
+		Name: field(line, columns[2]),
+	}
+	if station.ID == "" {
+		return station, errors.New("missing station id")
+	}
+	// stations without ICAO code are listed with dashes
+	if strings.Trim(station.ICAO, "-") == "" {
+		station.ICAO = ""
+	}
+	// only stations with a five digit id are WMO stations
+	if _, err := strconv.Atoi(station.ID); err == nil && len(station.ID) == 5 {
+		station.WMOID = station.ID
+	}
+
+	var err error
+	station.Latitude, err = parseDegreesMinutes(field(line, columns[3]))
+	if err != nil {
+		return station, err
+	}
+	station.Longitude, err = parseDegreesMinutes(field(line, columns[4]))
+	if err != nil {
+		return station, err
+	}
+	station.Elevation, err = strconv.Atoi(field(line, columns[5]))
+	if err != nil {
+		return station, err
+	}
+
+	return station, nil
+}
+
+// parseDegreesMinutes converts the catalogs degrees.minutes notation, like
+// -8.40 for 8 degrees and 40 minutes west, to decimal degrees
+func parseDegreesMinutes(value string) (float64, error) {
+	negative := strings.HasPrefix(value, "-")
+	parts := strings.SplitN(strings.TrimPrefix(value, "-"), ".", 2)
+
+	degrees, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+	minutes := 0
+	if len(parts) == 2 {
+		minutes, err = strconv.Atoi(parts[1])
+		if err != nil || len(parts[1]) != 2 || minutes >= 60 {
+			return 0, fmt.Errorf("invalid coordinate %q", value)
+		}
+	}
+
+	decimal := float64(degrees) + float64(minutes)/60
+	if negative {
+		decimal = -decimal
+	}
+	return decimal, nil
+}
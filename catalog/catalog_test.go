@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+)
+
+const testCatalog = `  MOSMIX station catalog
+
+ID    ICAO NAME                 LAT    LON     ELEV
+----- ---- -------------------- -----  ------- -----
+01001 ENJA JAN MAYEN             70.56   -8.40    10
+10315 ---- M` + "\xdc" + `NSTER/OSNABRUECK    52.08    7.42    48
+
+P0036 ---- LONDON-HEATHROW       51.29   -0.27    25
+SV01  ---- SOUTH POLE           -89.59 -139.16  2835
+`
+
+func TestParse(t *testing.T) {
+	stations, err := Parse(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []mosmix.Station{
+		{ID: "01001", WMOID: "01001", ICAO: "ENJA", Name: "JAN MAYEN", Latitude: 70 + 56.0/60, Longitude: -(8 + 40.0/60), Elevation: 10},
+		{ID: "10315", WMOID: "10315", Name: "MÜNSTER/OSNABRUECK", Latitude: 52 + 8.0/60, Longitude: 7 + 42.0/60, Elevation: 48},
+		{ID: "P0036", Name: "LONDON-HEATHROW", Latitude: 51 + 29.0/60, Longitude: -27.0 / 60, Elevation: 25},
+		{ID: "SV01", Name: "SOUTH POLE", Latitude: -(89 + 59.0/60), Longitude: -(139 + 16.0/60), Elevation: 2835},
+	}
+	if len(stations) != len(want) {
+		t.Fatalf("got %d stations, want %d", len(stations), len(want))
+	}
+	for i, station := range stations {
+		if math.Abs(station.Latitude-want[i].Latitude) > 1e-9 || math.Abs(station.Longitude-want[i].Longitude) > 1e-9 {
+			t.Errorf("%s: got coordinates %f,%f, want %f,%f", station.ID, station.Latitude, station.Longitude, want[i].Latitude, want[i].Longitude)
+		}
+		station.Latitude, station.Longitude = want[i].Latitude, want[i].Longitude
+		if !reflect.DeepEqual(station, want[i]) {
+			t.Errorf("got %+v, want %+v", station, want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "ID    ICAO NAME                 LAT    LON     ELEV\n----- ---- -------------------- -----  ------- -----\n"
+	tests := []struct {
+		name    string
+		catalog string
+		wantErr string
+	}{
+		{"no header", "01001 ENJA JAN MAYEN             70.56   -8.40    10\n", "no column header found in station catalog"},
+		{"missing column", "----- ---- -------------------- -----  -------\n", "line 1: expected 6 columns, found 5"},
+		{"minutes above 59", header + "01001 ENJA JAN MAYEN             70.56   -8.75    10\n", `line 3: invalid coordinate "-8.75"`},
+		{"single digit minutes", header + "01001 ENJA JAN MAYEN             70.5    -8.40    10\n", `line 3: invalid coordinate "70.5"`},
+		{"missing id", header + "      ENJA JAN MAYEN             70.56   -8.40    10\n", "line 3: missing station id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(test.catalog))
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDegreesMinutes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"7.42", 7.7},
+		{"-8.40", -8 - 40.0/60},
+		{"-0.30", -0.5},
+		{"0.00", 0},
+		{"52", 52},
+		{"-139.16", -139 - 16.0/60},
+	}
+
+	for _, test := range tests {
+		got, err := parseDegreesMinutes(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got %f, want %f", test.value, got, test.want)
+		}
+	}
+}
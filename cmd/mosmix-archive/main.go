@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	mosmixDB "github.com/codeformuenster/mosmix-processor/db"
+)
+
+func main() {
+	dbPath := flag.String("db", "", "postgis db connection string")
+	issueTimeFlag := flag.String("issue-time", "", "the RFC3339 issue time of the archived run")
+	station := flag.String("station", "", "the station ID of the forecasts")
+	flag.Parse()
+	schema := flag.Arg(0)
+	if *dbPath == "" || *issueTimeFlag == "" || *station == "" {
+		fmt.Println("Error: The db, issue-time and station parameters are required")
+		return
+	}
+	if schema == "" {
+		schema = "public"
+	}
+
+	issueTime, err := time.Parse(time.RFC3339, *issueTimeFlag)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	variables, err := mosmixDB.ArchivedForecast(*dbPath, schema, issueTime, *station)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if variables == nil {
+		fmt.Printf("Error: No archived forecasts of station %s issued at %s\n", *station, issueTime.Format(time.RFC3339))
+		return
+	}
+
+	fmt.Println("element,timestep,value")
+	for _, variable := range variables {
+		for _, value := range variable.Values {
+			fmt.Printf("%s,%s,%g\n", variable.Name, value.Timestep.UTC().Format(time.RFC3339), value.Value)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	mosmixDB "github.com/codeformuenster/mosmix-processor/db"
+	"github.com/codeformuenster/mosmix-processor/internal/flags"
+	mosmixURL "github.com/codeformuenster/mosmix-processor/url"
+	mosmixXML "github.com/codeformuenster/mosmix-processor/xml"
+)
+
+func process(url, dbPath, schema string, dbOptions mosmixDB.Options, options mosmixXML.Options) error {
+	db, err := mosmixDB.NewMosmixDB(dbPath, schema, dbOptions)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return mosmixXML.DownloadAndParse(url, db, options)
+}
+
+func main() {
+	dbPath := flag.String("db", "", "postgis db connection string")
+	dryRun := flag.Bool("dry-run", false, "only list the runs which would be processed")
+	baseURL := flag.String("base-url", mosmixURL.BaseURL, "the url of the mosmix directory, change it to use a mirror")
+	definitionsURL := flag.String("definitions-url", mosmixXML.MetElementDefinitionURL, "the url of MetElementDefinition.xml")
+	ingest := flags.RegisterIngest()
+	tables := flags.RegisterTables()
+	flag.Parse()
+	mosmixURL.BaseURL = strings.TrimSuffix(*baseURL, "/")
+	mosmixXML.MetElementDefinitionURL = *definitionsURL
+	schema := flag.Arg(0)
+	if *dbPath == "" {
+		fmt.Println("Error: Missing db parameter (postgres connection URI)")
+		return
+	}
+
+	dbOptions, err := tables.Options()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	if *tables.RetentionDryRun {
+		flags.PrintDroppedRuns(*dbPath, schema, dbOptions.Retention)
+		return
+	}
+
+	options, err := ingest.Options()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	runs, err := mosmixURL.Discover(schema)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	processed, err := mosmixDB.ProcessedSourceURLs(*dbPath, schema)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// process the missing runs from the oldest to the newest
+	for _, run := range runs {
+		if processed[run.URL] {
+			continue
+		}
+
+		fmt.Printf("Run %s (%d bytes, last modified %s) has not been processed\n",
+			run.Run.Format("2006-01-02 15Z"), run.Size, run.LastModified.Format("2006-01-02 15:04"))
+		if *dryRun {
+			continue
+		}
+
+		err = process(run.URL, *dbPath, schema, dbOptions, options)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+}
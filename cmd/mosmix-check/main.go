@@ -4,12 +4,20 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	mosmixURL "github.com/codeformuenster/mosmix-processor/url"
 )
 
 func checkAvailable(url string) bool {
+	// local mirrors only need to contain the file
+	if strings.HasPrefix(url, "file://") {
+		_, err := os.Stat(strings.TrimPrefix(url, "file://"))
+		return err == nil
+	}
+
 	// request the url
 	resp, err := http.Head(url)
 	if err != nil {
@@ -38,7 +46,11 @@ func checkAvailable(url string) bool {
 
 func main() {
 	intervalFlag := flag.String("interval", "20s", "the interval between checks. Parsed by time.ParseDuration")
+	runFlag := flag.String("run", "", "check for the run issued at or before this RFC3339 time instead of the latest run expected to be available")
+	nextFlag := flag.Bool("next", false, "check for the run after the latest run expected to be available")
+	baseURL := flag.String("base-url", mosmixURL.BaseURL, "the url of the mosmix directory, change it to use a mirror")
 	flag.Parse()
+	mosmixURL.BaseURL = strings.TrimSuffix(*baseURL, "/")
 	schema := flag.Arg(0)
 
 	sleepInterval, err := time.ParseDuration(*intervalFlag)
@@ -47,7 +59,26 @@ func main() {
 		return
 	}
 
-	url, err := mosmixURL.Generate(schema)
+	calculator, err := mosmixURL.NewRunCalculator(schema)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	run := calculator.Latest()
+	if *nextFlag {
+		run = calculator.Next()
+	}
+	if *runFlag != "" {
+		runTime, err := time.Parse(time.RFC3339, *runFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		run = calculator.RunAt(runTime)
+	}
+
+	url, err := mosmixURL.GenerateForRun(schema, run)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -3,8 +3,16 @@ package main
 import (
 	"flag"
 	"fmt"
+	"strings"
+	"time"
+	// time zones for the scratch image
+	_ "time/tzdata"
 
+	mosmix "github.com/codeformuenster/mosmix-processor"
 	mosmixDB "github.com/codeformuenster/mosmix-processor/db"
+	"github.com/codeformuenster/mosmix-processor/gpkg"
+	"github.com/codeformuenster/mosmix-processor/internal/flags"
+	"github.com/codeformuenster/mosmix-processor/poi"
 	mosmixURL "github.com/codeformuenster/mosmix-processor/url"
 	mosmixXML "github.com/codeformuenster/mosmix-processor/xml"
 )
@@ -12,19 +20,141 @@ import (
 func main() {
 	urlToDownload := flag.String("src", "", "the url to download")
 	dbPath := flag.String("db", "", "postgis db connection string")
+	gpkgPath := flag.String("gpkg", "", "write the run into this GeoPackage file instead of PostGIS. The file is replaced by every run")
+	localFile := flag.String("file", "", "local KML, KMZ, gzip or bzip2 compressed KML file to parse instead of downloading. Every KML document of an archive is ingested as its own run, which the retention flags have to keep. Use \"-\" for stdin")
+	definitionsFile := flag.String("definitions", "", "local MetElementDefinition.xml, required with the file flag")
+	stationsFlag := flag.String("stations", "", "comma separated list of station IDs. Only the single station files of these stations are processed")
+	runFlag := flag.String("run", "", "process the run issued at or before this RFC3339 time instead of the latest run expected to be available. With the stations parameter the single station files of this run are processed")
+	baseURL := flag.String("base-url", mosmixURL.BaseURL, "the url of the mosmix directory, change it to use a mirror")
+	definitionsURL := flag.String("definitions-url", mosmixXML.MetElementDefinitionURL, "the url of MetElementDefinition.xml")
+	format := flag.String("format", "kml", "the format of the files to process, either \"kml\" or \"poi\" (the POI csv files of the stations flag)")
+	poiBaseURL := flag.String("poi-base-url", mosmixURL.POIBaseURL, "the url of the POI directory, change it to use a mirror")
+	poiTimezone := flag.String("poi-timezone", "UTC", "the time zone of the dates and times in the POI files")
+	filterStations := flag.String("filter-stations", "", "comma separated list of station IDs. Only these stations are ingested")
+	filterBBox := flag.String("filter-bbox", "", "only ingest the stations inside this bounding box, given as minLon,minLat,maxLon,maxLat")
+	filterGeoJSON := flag.String("filter-geojson", "", "only ingest the stations inside the polygons of this GeoJSON file")
+	elements := flag.String("elements", "", "comma separated list of forecast elements, like TTT,Td,FF. Only these elements are ingested")
+	excludeElements := flag.String("exclude-elements", "", "comma separated list of forecast elements which are not ingested")
+	maxLeadTime := flag.Duration("max-lead-time", 0, "only ingest the timesteps at most this long after the issue time of the run, like 72h. All timesteps when 0")
+	ingest := flags.RegisterIngest()
+	tables := flags.RegisterTables()
 	flag.Parse()
+	mosmixURL.BaseURL = strings.TrimSuffix(*baseURL, "/")
+	mosmixURL.POIBaseURL = strings.TrimSuffix(*poiBaseURL, "/")
+	mosmixXML.MetElementDefinitionURL = *definitionsURL
 	schema := flag.Arg(0)
-	if *dbPath == "" {
-		fmt.Println("Error: Missing db parameter (postgres connection URI)")
+	if *dbPath == "" && *gpkgPath == "" {
+		fmt.Println("Error: Missing db parameter (postgres connection URI) or gpkg parameter (GeoPackage file)")
 		return
 	}
 
-	if *urlToDownload == "" {
-		url, err := mosmixURL.Generate(schema)
+	dbOptions, err := tables.Options()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	if *tables.RetentionDryRun {
+		if *dbPath == "" {
+			fmt.Println("Error: Missing db parameter (the retention flags apply to PostGIS only)")
+			return
+		}
+		if schema == "" {
+			schema = "public"
+		}
+		flags.PrintDroppedRuns(*dbPath, schema, dbOptions.Retention)
+		return
+	}
+
+	options, err := ingest.Options()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+	options.Filter, err = mosmixXML.NewFilter(*filterStations, *filterBBox, *filterGeoJSON)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *elements != "" {
+		options.AllowElements = strings.Split(*elements, ",")
+	}
+	if *excludeElements != "" {
+		options.DenyElements = strings.Split(*excludeElements, ",")
+	}
+	options.MaxLeadTime = *maxLeadTime
+
+	if *localFile != "" && *definitionsFile == "" {
+		fmt.Println("Error: Missing definitions parameter (required with the file parameter)")
+		return
+	}
+
+	if *format == "poi" {
+		if *stationsFlag == "" || *localFile != "" {
+			fmt.Println("Error: The poi format requires the stations parameter and can't read local files")
+			return
+		}
+		if *runFlag != "" {
+			fmt.Println("Error: The poi format only provides the latest run and can't be combined with the run parameter")
+			return
+		}
+		location, err := time.LoadLocation(*poiTimezone)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		poi.Location = location
+	} else if *format != "kml" {
+		fmt.Println("Error: Unknown format, either \"kml\" or \"poi\"")
+		return
+	}
+
+	var runTime time.Time
+	if *runFlag != "" {
+		runTime, err = time.Parse(time.RFC3339, *runFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var stationURLs []string
+	if *format == "poi" {
+		stationURLs = mosmixURL.GeneratePOI(strings.Split(*stationsFlag, ","))
+	} else if *stationsFlag != "" {
+		stationIDs := strings.Split(*stationsFlag, ",")
+		var urls []string
+		if *runFlag != "" {
+			var calculator *mosmixURL.RunCalculator
+			calculator, err = mosmixURL.NewRunCalculator(schema)
+			if err == nil {
+				urls, err = mosmixURL.GenerateStationsForRun(schema, stationIDs, calculator.RunAt(runTime))
+			}
+		} else {
+			urls, err = mosmixURL.GenerateStations(schema, stationIDs)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		stationURLs = urls
+	}
+
+	if *urlToDownload == "" && *localFile == "" && stationURLs == nil {
+		calculator, err := mosmixURL.NewRunCalculator(schema)
 		if err != nil {
 			fmt.Println("Error: src flag is required on missing or invalid mosmix type argument (either \"mosmix_s\" or \"mosmix_l\")")
 			return
 		}
+		run := calculator.Latest()
+		if *runFlag != "" {
+			run = calculator.RunAt(runTime)
+		}
+		url, err := mosmixURL.GenerateForRun(schema, run)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 		*urlToDownload = url
 	}
 
@@ -32,20 +162,27 @@ func main() {
 		schema = "public"
 	}
 
-	db, err := mosmixDB.NewMosmixDB(*dbPath, schema)
-	if err != nil {
-		fmt.Println(err)
-		return
+	var sink mosmix.Sink
+	if *gpkgPath != "" {
+		sink, err = gpkg.NewGeoPackage(*gpkgPath)
+	} else {
+		sink, err = mosmixDB.NewMosmixDB(*dbPath, schema, dbOptions)
 	}
-	defer db.Close()
-
-	err = mosmixXML.DownloadAndParse(*urlToDownload, db)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	defer sink.Close()
 
-	err = db.Finalize()
+	if *localFile != "" {
+		err = mosmixXML.ParseLocal(*localFile, *definitionsFile, sink, options)
+	} else if *format == "poi" {
+		err = mosmixXML.DownloadAndParsePOI(stationURLs, sink, options)
+	} else if stationURLs != nil {
+		err = mosmixXML.DownloadAndParseAll(stationURLs, sink, options)
+	} else {
+		err = mosmixXML.DownloadAndParse(*urlToDownload, sink, options)
+	}
 	if err != nil {
 		fmt.Println(err)
 		return
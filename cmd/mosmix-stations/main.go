@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codeformuenster/mosmix-processor/catalog"
+	mosmixDB "github.com/codeformuenster/mosmix-processor/db"
+	"github.com/codeformuenster/mosmix-processor/fetch"
+)
+
+func main() {
+	urlToDownload := flag.String("src", catalog.URL, "the url of the station catalog")
+	localFile := flag.String("file", "", "local station catalog to read instead of downloading")
+	dbPath := flag.String("db", "", "postgis db connection string")
+	flag.Parse()
+	schema := flag.Arg(0)
+	if *dbPath == "" {
+		fmt.Println("Error: Missing db parameter (postgres connection URI)")
+		return
+	}
+	if schema == "" {
+		schema = "public"
+	}
+
+	filename := *localFile
+	if filename == "" {
+		tmpfile, err := ioutil.TempFile("", "mosmix")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		tmpfile.Close()
+		filename = tmpfile.Name()
+		defer os.Remove(filename)
+
+		fmt.Printf("Downloading station catalog %v .... ", *urlToDownload)
+		_, err = fetch.DefaultRetryPolicy.Retry(func() error {
+			return fetch.Fetch(*urlToDownload, filename)
+		})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("done")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer file.Close()
+
+	stations, err := catalog.Parse(file)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Inserting %d stations .... ", len(stations))
+	err = mosmixDB.InsertStations(*dbPath, schema, stations)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("done")
+}
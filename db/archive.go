@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+)
+
+// archiveSchema returns the name of the archive schema of the given schema
+func archiveSchema(schema string) string {
+	return schema + "_archive"
+}
+
+// the forecasts of a place and element are stored as one row with arrays of
+// the lead times in minutes after the issue time and of the values. The low
+// toast_tuple_target makes PostgreSQL compress the arrays. The places are
+// stored for every issue time, so moved stations keep their former geometry
+const createArchiveTablesStmt = `BEGIN;
+
+	CREATE SCHEMA IF NOT EXISTS %[1]s;
+
+	CREATE TABLE IF NOT EXISTS %[1]s.runs(
+		processing_timestamp TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+		issue_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		source_url TEXT NOT NULL,
+		dwd_product_id TEXT NOT NULL,
+		dwd_available_forecast_variables TEXT[] NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_runs_issue_time ON %[1]s.runs (issue_time);
+
+	CREATE TABLE IF NOT EXISTS %[1]s.places(
+		issue_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		the_geom geometry(PointZ,4326) NOT NULL,
+		PRIMARY KEY (issue_time, id)
+	);
+
+	CREATE TABLE IF NOT EXISTS %[1]s.forecasts(
+		issue_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		place_id TEXT NOT NULL,
+		element TEXT NOT NULL,
+		lead_minutes INTEGER[] NOT NULL,
+		forecast_values DOUBLE PRECISION[] NOT NULL,
+		PRIMARY KEY (issue_time, place_id, element)
+	) WITH (toast_tuple_target = 128);
+
+	CREATE OR REPLACE FUNCTION %[1]s.archived_forecast(issue TIMESTAMP WITH TIME ZONE, place TEXT)
+	RETURNS TABLE (element TEXT, timestep TIMESTAMP WITH TIME ZONE, value DOUBLE PRECISION) AS $$
+		SELECT f.element, f.issue_time + l.lead_minutes * INTERVAL '1 minute', l.value
+		FROM %[1]s.forecasts f,
+			unnest(f.lead_minutes, f.forecast_values) AS l(lead_minutes, value)
+		WHERE f.issue_time = issue AND f.place_id = place
+		ORDER BY f.element, l.lead_minutes;
+	$$ LANGUAGE SQL STABLE STRICT;
+
+	COMMIT;`
+
+func (m *MosmixDB) createArchiveTables() error {
+	_, err := m.db.Exec(fmt.Sprintf(createArchiveTablesStmt, archiveSchema(m.schema)))
+	return err
+}
+
+// archiveRun appends the current run to the archive. Forecasts of an issue
+// time which has been archived before are kept, so runs of single stations
+// add their places to the archived forecasts of the issue time
+func (m *MosmixDB) archiveRun() error {
+	if !m.metadata.IssueTime.Valid {
+		fmt.Print("the run has no issue time and is not archived ... ")
+		return nil
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	// a no-op after the commit
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s.runs
+		(processing_timestamp, issue_time, source_url, dwd_product_id, dwd_available_forecast_variables)
+		SELECT processing_timestamp, $1::TIMESTAMP WITH TIME ZONE, source_url, dwd_product_id, dwd_available_forecast_variables
+		FROM metadata_%[2]s
+		ON CONFLICT DO NOTHING;`, archiveSchema(m.schema), m.runIdentifier),
+		m.metadata.IssueTime.Time)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s.places (issue_time, id, name, the_geom)
+		SELECT DISTINCT ON (id) $1::TIMESTAMP WITH TIME ZONE, id, name, the_geom
+		FROM forecast_places_%[2]s
+		ORDER BY id
+		ON CONFLICT DO NOTHING;`, archiveSchema(m.schema), m.runIdentifier),
+		m.metadata.IssueTime.Time)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s.forecasts (issue_time, place_id, element, lead_minutes, forecast_values)
+		SELECT $1::TIMESTAMP WITH TIME ZONE, place_id, name,
+			array_agg(round(EXTRACT(EPOCH FROM timestep - $1::TIMESTAMP WITH TIME ZONE) / 60)::INTEGER ORDER BY timestep),
+			array_agg(value ORDER BY timestep)
+		FROM forecasts_%[2]s
+		GROUP BY place_id, name
+		ON CONFLICT DO NOTHING;`, archiveSchema(m.schema), m.runIdentifier),
+		m.metadata.IssueTime.Time)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ArchivedForecast returns the forecasts of the given place issued at the
+// given time from the archive of the given schema. It is empty when the run or
+// the place has not been archived
+func ArchivedForecast(connectionString, schema string, issueTime time.Time, placeID string) ([]mosmix.ForecastVariable, error) {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(fmt.Sprintf("SELECT element, timestep, value FROM %s.archived_forecast($1, $2);", archiveSchema(schema)),
+		issueTime, placeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variables []mosmix.ForecastVariable
+	for rows.Next() {
+		var name string
+		var value mosmix.ForecastVariableTimestep
+		if err := rows.Scan(&name, &value.Timestep, &value.Value); err != nil {
+			return nil, err
+		}
+		if len(variables) == 0 || variables[len(variables)-1].Name != name {
+			variables = append(variables, mosmix.ForecastVariable{Name: name})
+		}
+		variables[len(variables)-1].Values = append(variables[len(variables)-1].Values, value)
+	}
+
+	return variables, rows.Err()
+}
@@ -3,11 +3,14 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
 	// import the postgres database driver
 	_ "github.com/lib/pq"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
 )
 
 const MosmixSSchemaName = "mosmix_s"
@@ -17,30 +20,62 @@ type MosmixDB struct {
 	db                  *sql.DB
 	ProcessingTimestamp time.Time
 	runIdentifier       string
-	metadata            *Metadata
+	metadata            *mosmix.Metadata
 	schema              string
+	options             Options
+	tablesCreated       bool
 }
 
-func NewMosmixDB(connectionString, schema string) (*MosmixDB, error) {
+var _ mosmix.Sink = &MosmixDB{}
+var _ mosmix.StationFinder = &MosmixDB{}
+var _ mosmix.RunKeeper = &MosmixDB{}
+
+// NewMosmixDB connects to the database, the tables are created by BeginRun
+func NewMosmixDB(connectionString, schema string, options Options) (*MosmixDB, error) {
 	fmt.Println("Connecting to database ... ")
-	db, err := sql.Open("postgres", connectionString)
+	db, err := sql.Open("postgres", withSessionParameters(connectionString, schema))
 	if err != nil {
 		return &MosmixDB{}, err
 	}
-	now := time.Now()
-	m := &MosmixDB{db, now, now.Format("20060102150405"), &Metadata{}, schema}
-	fmt.Print("Preparing tables ... ")
-	start := time.Now()
-	err = m.createTables()
-	if err != nil {
-		return &MosmixDB{}, err
+	if options.Partitioning == "" {
+		options.Partitioning = PartitioningInheritance
 	}
-	fmt.Printf("done in %s\n", time.Now().Sub(start))
 
-	return m, nil
+	return &MosmixDB{db: db, metadata: &mosmix.Metadata{}, schema: schema, options: options}, nil
+}
+
+// withSessionParameters adds the search_path and synchronous_commit settings
+// to the connection string, so every connection of the pool uses them and not
+// only the one which happened to run the SET statements
+func withSessionParameters(connectionString, schema string) string {
+	searchPath := fmt.Sprintf("%s,public", schema)
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			// let the driver report the invalid connection string
+			return connectionString
+		}
+		query := u.Query()
+		query.Set("search_path", searchPath)
+		query.Set("synchronous_commit", "off")
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	return fmt.Sprintf("%s search_path='%s' synchronous_commit=off", connectionString, searchPath)
 }
 
 func (m *MosmixDB) Finalize() error {
+	if m.options.Archive {
+		fmt.Print("Archiving run ... ")
+		start := time.Now()
+		err := m.archiveRun()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("done in %s\n", time.Now().Sub(start))
+	}
+
 	fmt.Print("Creating indexes ... ")
 	start := time.Now()
 	err := m.createIndexes()
@@ -52,55 +87,67 @@ func (m *MosmixDB) Finalize() error {
 	return nil
 }
 
-func (m *MosmixDB) Close() error {
-	return m.db.Close()
-}
+// BeginRun creates the tables of a new run. The tables of a run are named by
+// the second it started in, so it waits for the next second if necessary
+func (m *MosmixDB) BeginRun() (time.Time, error) {
+	fmt.Print("Preparing tables ... ")
+	start := time.Now()
+	if !m.tablesCreated {
+		err := m.createTables()
+		if err != nil {
+			return time.Time{}, err
+		}
+		m.tablesCreated = true
+	}
 
-func (m *MosmixDB) buildDropOldTablesQuery() (string, error) {
-	var err error
-	// query the table names to drop..
-	sqlStmt := fmt.Sprintf(`SELECT table_name
-	FROM information_schema.tables
-	WHERE table_schema='%s'
-	AND table_type='BASE TABLE'
-	AND table_name ~ E'_\\d{14}$'
-	AND table_name !~ '.*_%s$';`,
-		m.schema,
-		m.runIdentifier)
-	rows, err := m.db.Query(sqlStmt)
+	now := time.Now()
+	if now.Format("20060102150405") == m.runIdentifier {
+		time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		now = time.Now()
+	}
+	m.ProcessingTimestamp = now
+	m.runIdentifier = now.Format("20060102150405")
+	m.metadata = &mosmix.Metadata{}
+
+	err := m.createRunTables()
 	if err != nil {
-		return "", err
+		return time.Time{}, err
 	}
-	defer rows.Close()
+	fmt.Printf("done in %s\n", time.Now().Sub(start))
 
-	var dropStmt strings.Builder
+	return m.ProcessingTimestamp, nil
+}
 
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return "", err
-		}
-		dropStmt.WriteString(" DROP TABLE ")
-		dropStmt.WriteString(tableName)
-		dropStmt.WriteRune(';')
-	}
+// Abort drops the tables of the current run
+func (m *MosmixDB) Abort() error {
+	_, err := m.db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS
+		forecast_places_%[1]s,
+		forecasts_%[1]s,
+		metadata_%[1]s,
+		met_element_definitions_%[1]s,
+		rejected_placemarks_%[1]s;`, m.runIdentifier))
+	return err
+}
 
-	return dropStmt.String(), nil
+func (m *MosmixDB) Close() error {
+	return m.db.Close()
 }
 
 func (m *MosmixDB) buildCrosstabFunctionQuery() (string, error) {
 	// generate the list of forecast variables available
 	var forecastVariables []string
 	for _, fcVar := range m.metadata.AvailableVariables {
-		forecastVariables = append(forecastVariables, fmt.Sprintf("%s NUMERIC(8, 2)", fcVar))
+		forecastVariables = append(forecastVariables, fmt.Sprintf("%s DOUBLE PRECISION", fcVar))
 	}
 	forecastVariablesArgumentsString := strings.Join(forecastVariables, ", ")
 
 	functionSrc := fmt.Sprintf("SELECT * FROM crosstab("+
 		"'SELECT timestep, place_id, name, value FROM %[1]s.forecasts "+
 		"WHERE place_id = ' || quote_literal(place_id) || ' "+
+		"AND processing_timestamp = (SELECT max(processing_timestamp) FROM %[1]s.metadata) "+
 		"ORDER BY timestep, place_id', "+
-		"'SELECT DISTINCT(UNNEST(dwd_available_forecast_variables)) FROM %[1]s.metadata') "+
+		"'SELECT DISTINCT(UNNEST(dwd_available_forecast_variables)) FROM %[1]s.metadata "+
+		"WHERE processing_timestamp = (SELECT max(processing_timestamp) FROM %[1]s.metadata)') "+
 		"AS ct (timestep TIMESTAMP WITH TIME ZONE, place_id TEXT, %[2]s);",
 		m.schema, forecastVariablesArgumentsString)
 
@@ -150,8 +197,11 @@ func (m *MosmixDB) createIndexes() error {
 		return err
 	}
 
-	constraintFrom := m.ProcessingTimestamp.Add(-1 * time.Second).Format(time.RFC3339)
-	constraintTo := m.ProcessingTimestamp.Add(1 * time.Second).Format(time.RFC3339)
+	// the run tables hold the second of the run identifier, which makes the
+	// ranges of the partitions disjoint
+	from := m.ProcessingTimestamp.Truncate(time.Second)
+	constraintFrom := from.Format(time.RFC3339)
+	constraintTo := from.Add(1 * time.Second).Format(time.RFC3339)
 
 	sqlStmt := fmt.Sprintf(`BEGIN;
 
@@ -163,30 +213,30 @@ func (m *MosmixDB) createIndexes() error {
 
 	CREATE INDEX IF NOT EXISTS idx_the_geom_forecast_places_%[1]s ON forecast_places_%[1]s USING GIST (the_geom);
 
-	ALTER TABLE forecast_places_%[1]s INHERIT forecast_places;
-
 	ALTER TABLE forecasts_%[1]s ADD CONSTRAINT y%[1]s
 		CHECK ( processing_timestamp >= '%[2]s' AND processing_timestamp < '%[3]s' );
 
 	CREATE INDEX IF NOT EXISTS idx_forecasts_place_id_name_%[1]s ON forecasts_%[1]s (place_id, name);
 	CREATE INDEX IF NOT EXISTS idx_forecasts_place_id_%[1]s on forecasts_%[1]s (place_id);
 
-	ALTER TABLE forecasts_%[1]s INHERIT forecasts;
-
 	ALTER TABLE metadata_%[1]s ADD CONSTRAINT y%[1]s
 		CHECK ( processing_timestamp >= '%[2]s' AND processing_timestamp < '%[3]s' );
 
-	ALTER TABLE metadata_%[1]s INHERIT metadata;
-
 	ALTER TABLE met_element_definitions_%[1]s ADD CONSTRAINT y%[1]s
 		CHECK ( processing_timestamp >= '%[2]s' AND processing_timestamp < '%[3]s' );
 
-	ALTER TABLE met_element_definitions_%[1]s INHERIT met_element_definitions;
+	ALTER TABLE rejected_placemarks_%[1]s ADD CONSTRAINT y%[1]s
+		CHECK ( processing_timestamp >= '%[2]s' AND processing_timestamp < '%[3]s' );
+
+	INSERT INTO processed_runs (processing_timestamp, source_url, dwd_issue_time)
+		SELECT processing_timestamp, source_url, dwd_issue_time FROM metadata_%[1]s;
 
 	%[4]s
 
+	%[5]s
+
 	COMMIT;`,
-		m.runIdentifier, constraintFrom, constraintTo, dropStmt,
+		m.runIdentifier, constraintFrom, constraintTo, m.buildAttachRunTablesQuery(constraintFrom, constraintTo), dropStmt,
 	)
 	_, err = m.db.Exec(sqlStmt)
 	if err != nil {
@@ -231,9 +281,20 @@ func (m *MosmixDB) createTables() error {
 		return err
 	}
 
-	_, err = m.db.Exec(`BEGIN;
+	err = m.checkPartitioning()
+	if err != nil {
+		return err
+	}
+
+	parentTable, parentPartitioning := "UNLOGGED ", ""
+	if m.options.Partitioning == PartitioningDeclarative {
+		// partitioned tables have no storage of their own, the run tables stay
+		// unlogged
+		parentTable, parentPartitioning = "", " PARTITION BY RANGE (processing_timestamp)"
+	}
+	_, err = m.db.Exec(fmt.Sprintf(`BEGIN;
 
-	CREATE UNLOGGED TABLE IF NOT EXISTS metadata(
+	CREATE %[1]sTABLE IF NOT EXISTS metadata(
 		source_url TEXT NOT NULL,
 		processing_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
 		download_duration REAL NOT NULL,
@@ -245,39 +306,102 @@ func (m *MosmixDB) createTables() error {
 		dwd_available_forecast_variables TEXT[] NOT NULL,
 		dwd_available_timesteps TIMESTAMP WITH TIME ZONE[] NOT NULL,
 		dwd_referenced_models dwd_referenced_model[] NOT NULL
-	);
+	)%[2]s;
+
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS definitions_source TEXT NOT NULL DEFAULT 'download';
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS download_retries INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS filter TEXT NOT NULL DEFAULT '';
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS rejected_placemarks INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS dwd_issue_time TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS dwd_default_undef_sign TEXT;
+	ALTER TABLE metadata ADD COLUMN IF NOT EXISTS dwd_format_version TEXT NOT NULL DEFAULT '';
 
-	CREATE UNLOGGED TABLE IF NOT EXISTS forecast_places(
+	CREATE %[1]sTABLE IF NOT EXISTS forecast_places(
 		id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		the_geom geometry(PointZ,4326) NOT NULL,
 		processing_timestamp TIMESTAMP WITH TIME ZONE NOT NULL
-	);
+	)%[2]s;
 
-	CREATE UNLOGGED TABLE IF NOT EXISTS forecasts(
+	CREATE %[1]sTABLE IF NOT EXISTS forecasts(
 		place_id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		timestep TIMESTAMP WITH TIME ZONE NOT NULL,
-		value NUMERIC(8, 2) NOT NULL,
+		value DOUBLE PRECISION NOT NULL,
 		processing_timestamp TIMESTAMP WITH TIME ZONE NOT NULL
-	);
+	)%[2]s;
 
-	CREATE UNLOGGED TABLE IF NOT EXISTS met_element_definitions(
+	-- values used to be rounded to NUMERIC(8, 2)
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'forecasts'
+			AND column_name = 'value' AND data_type = 'numeric') THEN
+			ALTER TABLE forecasts ALTER COLUMN value TYPE DOUBLE PRECISION;
+		END IF;
+	END$$;
+
+	CREATE %[1]sTABLE IF NOT EXISTS met_element_definitions(
 		description TEXT NOT NULL,
 		unit_of_measurement TEXT NOT NULL,
 		short_name TEXT NOT NULL,
 		processing_timestamp TIMESTAMP WITH TIME ZONE NOT NULL
+	)%[2]s;
+
+	CREATE %[1]sTABLE IF NOT EXISTS rejected_placemarks(
+		line INTEGER,
+		place_id TEXT,
+		reason TEXT NOT NULL,
+		processing_timestamp TIMESTAMP WITH TIME ZONE NOT NULL
+	)%[2]s;
+
+	-- every finalized run, also the ones dropped by the retention policy
+	CREATE TABLE IF NOT EXISTS processed_runs(
+		processing_timestamp TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+		source_url TEXT NOT NULL,
+		dwd_issue_time TIMESTAMP WITH TIME ZONE
 	);
 
 	SET synchronous_commit TO off;
 
 	COMMIT;
-	`)
+	`, parentTable, parentPartitioning))
 	if err != nil {
 		return err
 	}
 
-	_, err = m.db.Exec(fmt.Sprintf(`BEGIN;
+	if m.options.Partitioning == PartitioningDeclarative {
+		// attaching a run adopts the equal indexes of its tables
+		_, err = m.db.Exec(`BEGIN;
+
+		CREATE INDEX IF NOT EXISTS idx_the_geom_forecast_places ON forecast_places USING GIST (the_geom);
+		CREATE INDEX IF NOT EXISTS idx_forecasts_place_id_name ON forecasts (place_id, name);
+		CREATE INDEX IF NOT EXISTS idx_forecasts_place_id ON forecasts (place_id);
+
+		COMMIT;`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = m.db.Exec(fmt.Sprintf(createStationsTableStmt+createStationsViewStmt, m.schema))
+	if err != nil {
+		return err
+	}
+
+	if m.options.Archive {
+		err = m.createArchiveTables()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createRunTables creates the empty tables of the current run
+func (m *MosmixDB) createRunTables() error {
+	_, err := m.db.Exec(fmt.Sprintf(`BEGIN;
 
 	CREATE UNLOGGED TABLE forecast_places_%[1]s
 		(LIKE forecast_places INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
@@ -287,6 +411,8 @@ func (m *MosmixDB) createTables() error {
 		(LIKE metadata INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
 	CREATE UNLOGGED TABLE met_element_definitions_%[1]s
 		(LIKE met_element_definitions INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+	CREATE UNLOGGED TABLE rejected_placemarks_%[1]s
+		(LIKE rejected_placemarks INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
 
 	COMMIT;
 	`, m.runIdentifier))
@@ -0,0 +1,20 @@
+package db
+
+// Options configure how the tables of the runs are added to the schema, kept
+// and archived. The zero value uses PartitioningInheritance, keeps only the
+// current run and archives nothing
+type Options struct {
+	// Partitioning selects how the tables of a run are added to the parent
+	// tables. PartitioningInheritance makes them children of plain tables with
+	// INHERIT and relies on constraint exclusion. PartitioningDeclarative makes
+	// the parent tables partitioned by range of processing_timestamp and
+	// attaches the run tables as partitions, which needs PostgreSQL 12 or
+	// newer. A schema can't switch between them without dropping its tables
+	Partitioning string
+	// Retention is evaluated whenever a run is finalized
+	Retention RetentionPolicy
+	// Archive appends every finalized run to the archive schema, named like
+	// the schema with the suffix _archive. Unlike the run tables, the archive
+	// is never dropped
+	Archive bool
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// the ways the run tables are added to their parent tables, selected with
+// Options.Partitioning
+const (
+	PartitioningInheritance = "inheritance"
+	PartitioningDeclarative = "declarative"
+)
+
+// partitionedTables are the parent tables of the run tables
+var partitionedTables = []string{
+	"forecast_places",
+	"forecasts",
+	"metadata",
+	"met_element_definitions",
+	"rejected_placemarks",
+}
+
+// checkPartitioning fails when the parent tables exist with the other kind of
+// partitioning
+func (m *MosmixDB) checkPartitioning() error {
+	partitioning := m.options.Partitioning
+	if partitioning != PartitioningInheritance && partitioning != PartitioningDeclarative {
+		return fmt.Errorf("unknown partitioning %s", partitioning)
+	}
+
+	rows, err := m.db.Query(`SELECT c.relname, c.relkind = 'p'
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = ANY($2) AND c.relkind IN ('r', 'p');`,
+		m.schema, pq.Array(partitionedTables))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		var partitioned bool
+		if err := rows.Scan(&table, &partitioned); err != nil {
+			return err
+		}
+		if partitioned == (partitioning == PartitioningDeclarative) {
+			continue
+		}
+		existing := PartitioningInheritance
+		if partitioned {
+			existing = PartitioningDeclarative
+		}
+		return fmt.Errorf("table %s.%s uses %s partitioning instead of %s, drop the tables of the schema to switch",
+			m.schema, table, existing, partitioning)
+	}
+
+	return rows.Err()
+}
+
+// buildAttachRunTablesQuery adds the tables of the current run, which hold the
+// processing timestamps from the given time up to the other, to their parents
+func (m *MosmixDB) buildAttachRunTablesQuery(from, to string) string {
+	var attachStmt strings.Builder
+
+	for _, table := range partitionedTables {
+		if m.options.Partitioning == PartitioningDeclarative {
+			fmt.Fprintf(&attachStmt, " ALTER TABLE %[1]s ATTACH PARTITION %[1]s_%[2]s FOR VALUES FROM ('%[3]s') TO ('%[4]s');",
+				table, m.runIdentifier, from, to)
+		} else {
+			fmt.Fprintf(&attachStmt, " ALTER TABLE %[1]s_%[2]s INHERIT %[1]s;", table, m.runIdentifier)
+		}
+	}
+
+	return attachStmt.String()
+}
@@ -1,79 +1,49 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
+	mosmix "github.com/codeformuenster/mosmix-processor"
 	"github.com/lib/pq"
 )
 
-// for pq inserting
-type StringArray []string
+// stringArrayLiteral formats the strings as postgres array
+func stringArrayLiteral(strs []string) string {
+	return fmt.Sprintf("ARRAY['%s']", strings.Join(strs, "','"))
+}
 
-func (s StringArray) String() string {
-	return fmt.Sprintf("ARRAY['%s']", strings.Join(s, "','"))
+// timeArrayLiteral formats the times as postgres array
+func timeArrayLiteral(times []time.Time) string {
+	var strs []string
+	for _, timestep := range times {
+		strs = append(strs, timestep.Format(time.RFC3339Nano))
+	}
+	return stringArrayLiteral(strs)
 }
 
-type ReferencedModels []struct {
-	Name          string    `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd name,attr"`
-	ReferenceTime time.Time `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd referenceTime,attr"`
+// nullTimeLiteral formats the time as postgres literal
+func nullTimeLiteral(t mosmix.NullTime) string {
+	if !t.Valid {
+		return "NULL"
+	}
+	return fmt.Sprintf("'%s'", t.Time.Format(time.RFC3339Nano))
 }
 
-func (rs ReferencedModels) String() string {
+// referencedModelsLiteral formats the models as rows of dwd_referenced_model
+func referencedModelsLiteral(models []mosmix.ReferencedModel) string {
 	var strs []string
 
-	for _, rm := range rs {
+	for _, rm := range models {
 		strs = append(strs, fmt.Sprintf("('%s', '%s')", rm.Name, rm.ReferenceTime.Format(time.RFC3339)))
 	}
 
 	return strings.Join(strs, ",")
 }
 
-type Metadata struct {
-	ForecastTimeSteps  StringArray      `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ForecastTimeSteps>TimeStep"`
-	DefaultUndefSign   string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd FormatCfg>DefaultUndefSign"`
-	GeneratingProcess  string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd GeneratingProcess"`
-	Issuer             string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd Issuer"`
-	ProductID          string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ProductID"`
-	ReferencedModels   ReferencedModels `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ReferencedModel>Model"`
-	ProcessingTime     time.Time
-	DownloadDuration   time.Duration
-	ParsingDuration    time.Duration
-	SourceURL          string
-	AvailableVariables StringArray
-	// IssueTime is empty?!
-	// IssueTime       *time.Time `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd IssueTime,omitempty"`   // ZZmaxLength=0
-}
-
-type ForecastPlace struct {
-	ForecastVariables []struct {
-		Name      string `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd elementName,attr"`
-		RawValues string `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd value"`
-		Values    []ForecastVariableTimestep
-	} `xml:"ExtendedData>Forecast"` // Ignore namespace, because I don't know how to write this with namespace
-	Geometry KMLPoint `xml:"http://www.opengis.net/kml/2.2 Point>coordinates"`
-	Name     string   `xml:"http://www.opengis.net/kml/2.2 description"`
-	ID       string   `xml:"http://www.opengis.net/kml/2.2 name"`
-}
-
-type KMLPoint struct {
-	Longitude, Latitude, Altitude float64
-}
-
-type ForecastVariableTimestep struct {
-	Timestep string
-	Value    string // we rely on casting in sqlite
-}
-
-type MetElement struct {
-	ShortName         string `xml:"ShortName"`
-	UnitOfMeasurement string `xml:"UnitOfMeasurement"`
-	Description       string `xml:"Description"`
-}
-
-func (m *MosmixDB) InsertMetadata(metadata *Metadata) error {
+func (m *MosmixDB) InsertMetadata(metadata *mosmix.Metadata) error {
 	// don't do this at home!
 	queryStr := fmt.Sprintf(`INSERT INTO metadata_%s (
 		source_url,
@@ -86,20 +56,34 @@ func (m *MosmixDB) InsertMetadata(metadata *Metadata) error {
 		dwd_generating_process,
 		dwd_available_forecast_variables,
 		dwd_available_timesteps,
-		dwd_referenced_models
-		) values('%s', '%s', %d, %d, '%s', '%s', '%s', '%s', %s, %s::timestamp with time zone[], ARRAY[%s]::dwd_referenced_model[])`,
+		dwd_referenced_models,
+		definitions_source,
+		download_retries,
+		filter,
+		rejected_placemarks,
+		dwd_issue_time,
+		dwd_default_undef_sign,
+		dwd_format_version
+		) values('%s', '%s', %d, %d, '%s', '%s', '%s', '%s', %s, %s::timestamp with time zone[], ARRAY[%s]::dwd_referenced_model[], '%s', %d, '%s', %d, %s, '%s', '%s')`,
 		m.runIdentifier,
 		metadata.SourceURL,
 		metadata.ProcessingTime.Format(time.RFC3339),
 		metadata.DownloadDuration,
 		metadata.ParsingDuration,
 		"github.com/codeformuenster/mosmix-processor",
-		metadata.ProductID,
 		metadata.Issuer,
+		metadata.ProductID,
 		metadata.GeneratingProcess,
-		metadata.AvailableVariables,
-		metadata.ForecastTimeSteps,
-		metadata.ReferencedModels)
+		stringArrayLiteral(metadata.AvailableVariables),
+		timeArrayLiteral(metadata.ForecastTimeSteps),
+		referencedModelsLiteral(metadata.ReferencedModels),
+		metadata.DefinitionsSource,
+		metadata.DownloadRetries,
+		strings.Replace(metadata.Filter, "'", "''", -1),
+		metadata.RejectedPlacemarks,
+		nullTimeLiteral(metadata.IssueTime),
+		strings.Replace(metadata.DefaultUndefSign, "'", "''", -1),
+		metadata.FormatVersion)
 	_, err := m.db.Exec(queryStr)
 	if err != nil {
 		return err
@@ -110,11 +94,13 @@ func (m *MosmixDB) InsertMetadata(metadata *Metadata) error {
 	return nil
 }
 
-func (m *MosmixDB) InsertForecast(forecast *ForecastPlace) error {
+func (m *MosmixDB) InsertForecast(forecast *mosmix.ForecastPlace) error {
 	tx, err := m.db.Begin()
 	if err != nil {
 		return err
 	}
+	// a no-op after the commit
+	defer tx.Rollback()
 
 	_, err = tx.Exec(fmt.Sprintf("INSERT INTO forecast_places_%s (id, name, the_geom, processing_timestamp) VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4, $5), 4326), $6);", m.runIdentifier),
 		forecast.ID, forecast.Name, forecast.Geometry.Longitude, forecast.Geometry.Latitude, forecast.Geometry.Altitude, m.ProcessingTimestamp)
@@ -124,7 +110,7 @@ func (m *MosmixDB) InsertForecast(forecast *ForecastPlace) error {
 
 	stmt, err := tx.Prepare(pq.CopyIn(fmt.Sprintf("forecasts_%s", m.runIdentifier), "place_id", "name", "timestep", "value", "processing_timestamp"))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	for _, variable := range forecast.ForecastVariables {
@@ -147,15 +133,17 @@ func (m *MosmixDB) InsertForecast(forecast *ForecastPlace) error {
 	return nil
 }
 
-func (m *MosmixDB) InsertMetDefinitions(metDefinitions *[]MetElement) error {
+func (m *MosmixDB) InsertMetDefinitions(metDefinitions *[]mosmix.MetElement) error {
 	tx, err := m.db.Begin()
 	if err != nil {
 		return err
 	}
+	// a no-op after the commit
+	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(pq.CopyIn(fmt.Sprintf("met_element_definitions_%s", m.runIdentifier), "short_name", "unit_of_measurement", "description", "processing_timestamp"))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	for _, metElement := range *metDefinitions {
@@ -175,3 +163,60 @@ func (m *MosmixDB) InsertMetDefinitions(metDefinitions *[]MetElement) error {
 	}
 	return nil
 }
+
+// ProcessedSourceURLs returns the source urls of all runs finalized in the
+// given schema, including the ones dropped by the retention policy, and of the
+// runs in its archive
+func ProcessedSourceURLs(connectionString, schema string) (map[string]bool, error) {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	processed := make(map[string]bool)
+
+	// the metadata table holds the runs finalized before processed_runs
+	// existed
+	for _, table := range []string{schema + ".metadata", schema + ".processed_runs", archiveSchema(schema) + ".runs"} {
+		err := addSourceURLs(db, table, processed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return processed, nil
+}
+
+// addSourceURLs adds the source urls of the given table, which may not exist
+// yet, to the processed urls
+func addSourceURLs(db *sql.DB, table string, processed map[string]bool) error {
+	var regclass sql.NullString
+	err := db.QueryRow(fmt.Sprintf("SELECT to_regclass('%s')::text;", table)).Scan(&regclass)
+	if err != nil {
+		return err
+	}
+	if !regclass.Valid {
+		return nil
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT source_url FROM %s;", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sourceURL string
+		if err := rows.Scan(&sourceURL); err != nil {
+			return err
+		}
+		// runs of single station files list all their urls, runs of an
+		// archive holding several documents add the document as fragment
+		for _, url := range strings.Split(sourceURL, ",") {
+			processed[strings.SplitN(url, "#", 2)[0]] = true
+		}
+	}
+
+	return rows.Err()
+}
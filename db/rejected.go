@@ -0,0 +1,19 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+)
+
+// InsertRejectedPlacemark stores a skipped Placemark with the reason in the
+// rejected_placemarks table of the run
+func (m *MosmixDB) InsertRejectedPlacemark(rejected *mosmix.RejectedPlacemark) error {
+	line := sql.NullInt64{Int64: int64(rejected.Line), Valid: rejected.Line > 0}
+	id := sql.NullString{String: rejected.ID, Valid: rejected.ID != ""}
+
+	_, err := m.db.Exec(fmt.Sprintf("INSERT INTO rejected_placemarks_%s (line, place_id, reason, processing_timestamp) VALUES ($1, $2, $3, $4);", m.runIdentifier),
+		line, id, rejected.Reason, m.ProcessingTimestamp)
+	return err
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy selects the older runs which are kept when a run is
+// finalized, the tables of all other runs are dropped. A run is kept when any
+// of the rules keeps it, the zero value keeps only the current run
+type RetentionPolicy struct {
+	// KeepLast is the number of runs kept, including the current one
+	KeepLast int
+	// KeepFor keeps the runs processed within this duration before the
+	// current run
+	KeepFor time.Duration
+	// KeepDailyFor keeps the first run issued on every UTC day within this
+	// duration before the current run, like the 00Z run of MOSMIX_S or the 03Z
+	// run of MOSMIX_L. Reprocessed runs keep the newest one
+	KeepDailyFor time.Duration
+}
+
+// KeepsRuns reports whether the Retention keeps all runs of the given issue
+// times when they are finalized in a row
+func (m *MosmixDB) KeepsRuns(issueTimes []time.Time) bool {
+	return m.options.Retention.keepsRuns(time.Now(), issueTimes)
+}
+
+// finishedRun is a run which has been finalized
+type finishedRun struct {
+	identifier          string
+	processingTimestamp time.Time
+	issueTime           sql.NullTime
+}
+
+// keep returns the identifiers of the runs to keep besides the current one
+func (p RetentionPolicy) keep(current time.Time, runs []finishedRun) map[string]bool {
+	kept := make(map[string]bool)
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].processingTimestamp.After(runs[j].processingTimestamp)
+	})
+	// the first run of every day, the newest one of reprocessed runs
+	firstOfDay := make(map[string]finishedRun)
+	for i, run := range runs {
+		if i < p.KeepLast-1 {
+			kept[run.identifier] = true
+		}
+		if current.Sub(run.processingTimestamp) < p.KeepFor {
+			kept[run.identifier] = true
+		}
+
+		if !run.issueTime.Valid {
+			continue
+		}
+		day := run.issueTime.Time.UTC().Format("2006-01-02")
+		first, ok := firstOfDay[day]
+		if !ok || run.issueTime.Time.Before(first.issueTime.Time) {
+			firstOfDay[day] = run
+		}
+	}
+	for _, run := range firstOfDay {
+		if current.Sub(run.issueTime.Time) < p.KeepDailyFor {
+			kept[run.identifier] = true
+		}
+	}
+
+	return kept
+}
+
+// keepsRuns reports whether all runs of the given issue times are kept when
+// they are processed in a row, the last one at current
+func (p RetentionPolicy) keepsRuns(current time.Time, issueTimes []time.Time) bool {
+	if len(issueTimes) < 2 {
+		return true
+	}
+
+	// all runs but the last one are older runs once the last one is finalized
+	older := make([]finishedRun, len(issueTimes)-1)
+	for i, issueTime := range issueTimes[:len(older)] {
+		older[i] = finishedRun{
+			identifier:          strconv.Itoa(i),
+			processingTimestamp: current,
+			issueTime:           sql.NullTime{Time: issueTime, Valid: !issueTime.IsZero()},
+		}
+	}
+	return len(p.keep(current, older)) == len(older)
+}
+
+// DroppedRun is a run whose tables are dropped by the retention policy
+type DroppedRun struct {
+	Identifier string
+	// Finalized is false for the leftovers of failed runs
+	Finalized bool
+	IssueTime sql.NullTime
+}
+
+// droppedRuns returns the runs which are not kept by the Retention when the
+// run of the given time is finalized, together with their tables. Leftovers of
+// runs which have never been finalized are always dropped
+func (m *MosmixDB) droppedRuns(current time.Time) ([]DroppedRun, map[string][]string, error) {
+	// query the table names of all runs..
+	sqlStmt := fmt.Sprintf(`SELECT table_name
+	FROM information_schema.tables
+	WHERE table_schema='%s'
+	AND table_type='BASE TABLE'
+	AND table_name ~ E'_\\d{14}$'
+	AND table_name !~ '.*_%s$';`,
+		m.schema,
+		m.runIdentifier)
+	rows, err := m.db.Query(sqlStmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	runTables := make(map[string][]string)
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, nil, err
+		}
+		identifier := tableName[len(tableName)-14:]
+		runTables[identifier] = append(runTables[identifier], tableName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	runs, err := m.finishedRuns()
+	if err != nil {
+		return nil, nil, err
+	}
+	kept := m.options.Retention.keep(current, runs)
+	finished := make(map[string]finishedRun)
+	for _, run := range runs {
+		finished[run.identifier] = run
+	}
+
+	var dropped []DroppedRun
+	for identifier := range runTables {
+		if kept[identifier] {
+			continue
+		}
+		run, ok := finished[identifier]
+		dropped = append(dropped, DroppedRun{Identifier: identifier, Finalized: ok, IssueTime: run.issueTime})
+	}
+	sort.Slice(dropped, func(i, j int) bool {
+		return dropped[i].Identifier < dropped[j].Identifier
+	})
+
+	return dropped, runTables, nil
+}
+
+// buildDropOldTablesQuery drops the tables of the runs which are not kept by
+// the Retention
+func (m *MosmixDB) buildDropOldTablesQuery() (string, error) {
+	dropped, runTables, err := m.droppedRuns(m.ProcessingTimestamp)
+	if err != nil {
+		return "", err
+	}
+
+	var dropStmt strings.Builder
+
+	for _, run := range dropped {
+		for _, tableName := range runTables[run.Identifier] {
+			dropStmt.WriteString(" DROP TABLE ")
+			dropStmt.WriteString(tableName)
+			dropStmt.WriteRune(';')
+		}
+	}
+
+	return dropStmt.String(), nil
+}
+
+// RunsDroppedByRetention lists the runs of the given schema which the retention
+// policy drops when the next run is finalized, without processing a run
+func RunsDroppedByRetention(connectionString, schema string, retention RetentionPolicy) ([]DroppedRun, error) {
+	db, err := sql.Open("postgres", withSessionParameters(connectionString, schema))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	m := &MosmixDB{db: db, schema: schema, options: Options{Retention: retention}}
+	dropped, _, err := m.droppedRuns(time.Now())
+	return dropped, err
+}
+
+// finishedRuns lists the runs whose metadata table has been added to the
+// parent table
+func (m *MosmixDB) finishedRuns() ([]finishedRun, error) {
+	rows, err := m.db.Query(`SELECT c.relname
+	FROM pg_catalog.pg_inherits i
+	JOIN pg_catalog.pg_class c ON c.oid = i.inhrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname ~ '^metadata_\d{14}$';`, m.schema)
+	if err != nil {
+		return nil, err
+	}
+	var metadataTables []string
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		metadataTables = append(metadataTables, tableName)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var runs []finishedRun
+	for _, tableName := range metadataTables {
+		run := finishedRun{identifier: strings.TrimPrefix(tableName, "metadata_")}
+		err := m.db.QueryRow(fmt.Sprintf("SELECT processing_timestamp, dwd_issue_time FROM %s.%s LIMIT 1;", m.schema, tableName)).
+			Scan(&run.processingTimestamp, &run.issueTime)
+		switch {
+		case err == sql.ErrNoRows:
+			// the run failed after its tables have been added
+			continue
+		case err != nil:
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCurrent = time.Date(2026, 10, 18, 4, 30, 0, 0, time.UTC)
+
+// testRun is a run issued at the given time and processed 90 minutes later
+func testRun(identifier string, issueTime time.Time) finishedRun {
+	return finishedRun{
+		identifier:          identifier,
+		processingTimestamp: issueTime.Add(90 * time.Minute),
+		issueTime:           sql.NullTime{Time: issueTime, Valid: true},
+	}
+}
+
+func issued(day, hour int) time.Time {
+	return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestRetentionPolicyKeep(t *testing.T) {
+	// MOSMIX_L runs, issued every 6 hours from 03Z
+	mosmixL := []finishedRun{
+		testRun("l-16-03", issued(16, 3)),
+		testRun("l-16-09", issued(16, 9)),
+		testRun("l-16-15", issued(16, 15)),
+		testRun("l-16-21", issued(16, 21)),
+		testRun("l-17-03", issued(17, 3)),
+		testRun("l-17-09", issued(17, 9)),
+		testRun("l-17-15", issued(17, 15)),
+		testRun("l-17-21", issued(17, 21)),
+	}
+	// MOSMIX_S runs, issued hourly
+	mosmixS := []finishedRun{
+		testRun("s-17-00", issued(17, 0)),
+		testRun("s-17-01", issued(17, 1)),
+		testRun("s-18-00", issued(18, 0)),
+		testRun("s-18-01", issued(18, 1)),
+	}
+	reprocessed := testRun("s-17-00-reprocessed", issued(17, 0))
+	reprocessed.processingTimestamp = issued(17, 12)
+	unknownIssueTime := finishedRun{identifier: "unknown", processingTimestamp: issued(17, 22)}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		runs   []finishedRun
+		want   []string
+	}{
+		{"zero value", RetentionPolicy{}, mosmixL, nil},
+		{"keep the current run", RetentionPolicy{KeepLast: 1}, mosmixL, nil},
+		{"keep the last runs", RetentionPolicy{KeepLast: 3}, mosmixL, []string{"l-17-21", "l-17-15"}},
+		{"keep for", RetentionPolicy{KeepFor: 12 * time.Hour}, mosmixL, []string{"l-17-21"}},
+		{"daily MOSMIX_L", RetentionPolicy{KeepDailyFor: 72 * time.Hour}, mosmixL, []string{"l-16-03", "l-17-03"}},
+		{"daily within the duration", RetentionPolicy{KeepDailyFor: 30 * time.Hour}, mosmixL, []string{"l-17-03"}},
+		{"daily MOSMIX_S", RetentionPolicy{KeepDailyFor: 72 * time.Hour}, mosmixS, []string{"s-17-00", "s-18-00"}},
+		{"daily reprocessed", RetentionPolicy{KeepDailyFor: 72 * time.Hour},
+			append([]finishedRun{reprocessed}, mosmixS[:2]...), []string{"s-17-00-reprocessed"}},
+		{"daily without issue time", RetentionPolicy{KeepDailyFor: 72 * time.Hour},
+			[]finishedRun{unknownIssueTime}, nil},
+		{"rules combined", RetentionPolicy{KeepLast: 2, KeepDailyFor: 72 * time.Hour}, mosmixL,
+			[]string{"l-17-21", "l-16-03", "l-17-03"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := append([]finishedRun(nil), test.runs...)
+			got := test.policy.keep(testCurrent, runs)
+
+			want := make(map[string]bool)
+			for _, identifier := range test.want {
+				want[identifier] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyKeepsRuns(t *testing.T) {
+	current := issued(18, 12)
+	mosmixL := []time.Time{issued(17, 3), issued(17, 9), issued(17, 15), issued(17, 21), issued(18, 3)}
+	daily := []time.Time{issued(16, 3), issued(17, 3), issued(18, 3)}
+
+	tests := []struct {
+		name       string
+		policy     RetentionPolicy
+		issueTimes []time.Time
+		want       bool
+	}{
+		{"single run", RetentionPolicy{}, mosmixL[:1], true},
+		{"zero value", RetentionPolicy{}, mosmixL, false},
+		{"keep fewer runs", RetentionPolicy{KeepLast: 4}, mosmixL, false},
+		{"keep the last runs", RetentionPolicy{KeepLast: 5}, mosmixL, true},
+		{"keep for", RetentionPolicy{KeepLast: 1, KeepFor: time.Hour}, mosmixL, true},
+		{"daily, several runs a day", RetentionPolicy{KeepLast: 1, KeepDailyFor: 72 * time.Hour}, mosmixL, false},
+		{"daily, one run a day", RetentionPolicy{KeepLast: 1, KeepDailyFor: 72 * time.Hour}, daily, true},
+		{"daily, older than the duration", RetentionPolicy{KeepLast: 1, KeepDailyFor: 24 * time.Hour}, daily, false},
+		{"daily, unknown issue times", RetentionPolicy{KeepDailyFor: 72 * time.Hour}, []time.Time{{}, {}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.keepsRuns(current, test.issueTimes); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// catalogDriver answers the queries of finishedRuns for a single finalized
+// run of the mosmix_s schema. Like PostgreSQL without a search_path, it only
+// knows the metadata table by its qualified name
+type catalogDriver struct{}
+
+func (catalogDriver) Open(name string) (driver.Conn, error) { return catalogConn{}, nil }
+
+type catalogConn struct{}
+
+func (catalogConn) Prepare(query string) (driver.Stmt, error) { return catalogStmt(query), nil }
+func (catalogConn) Close() error                              { return nil }
+func (catalogConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type catalogStmt string
+
+func (s catalogStmt) Close() error  { return nil }
+func (s catalogStmt) NumInput() int { return -1 }
+func (s catalogStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s catalogStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(string(s), "pg_inherits"):
+		return &catalogRows{columns: []string{"relname"}, values: [][]driver.Value{{"metadata_20261017220000"}}}, nil
+	case strings.Contains(string(s), "FROM mosmix_s.metadata_20261017220000"):
+		return &catalogRows{columns: []string{"processing_timestamp", "dwd_issue_time"},
+			values: [][]driver.Value{{issued(17, 22), issued(17, 21)}}}, nil
+	}
+	return nil, fmt.Errorf("relation does not exist: %s", s)
+}
+
+type catalogRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *catalogRows) Columns() []string { return r.columns }
+func (r *catalogRows) Close() error      { return nil }
+func (r *catalogRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("catalog", catalogDriver{})
+}
+
+func TestFinishedRunsQualifiesTheMetadataTables(t *testing.T) {
+	db, err := sql.Open("catalog", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := &MosmixDB{db: db, schema: MosmixSSchemaName}
+	runs, err := m.finishedRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []finishedRun{testRun("20261017220000", issued(17, 21))}
+	want[0].processingTimestamp = issued(17, 22)
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("got runs %v, want %v", runs, want)
+	}
+}
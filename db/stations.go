@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+)
+
+const createStationsTableStmt = `CREATE TABLE IF NOT EXISTS %[1]s.stations(
+		id TEXT PRIMARY KEY,
+		wmo_id TEXT,
+		icao TEXT,
+		name TEXT NOT NULL,
+		elevation INTEGER NOT NULL,
+		the_geom geometry(PointZ,4326) NOT NULL
+	);`
+
+// stations_forecast_places lists every station of the catalog and of the
+// forecasts of the latest run, even when it is missing from one of them. The
+// older runs kept by the retention policy are left out
+const createStationsViewStmt = `CREATE OR REPLACE VIEW %[1]s.stations_forecast_places AS
+	SELECT COALESCE(p.id, s.id) AS id,
+		s.wmo_id,
+		s.icao,
+		s.name AS station_name,
+		p.name AS forecast_place_name,
+		s.elevation,
+		s.the_geom AS station_geom,
+		p.the_geom AS forecast_place_geom,
+		ST_Distance(s.the_geom::geography, p.the_geom::geography) AS distance,
+		p.processing_timestamp
+	FROM (SELECT * FROM %[1]s.forecast_places
+		WHERE processing_timestamp = (SELECT max(processing_timestamp) FROM %[1]s.metadata)) p
+	FULL OUTER JOIN %[1]s.stations s ON s.id = p.id;`
+
+// InsertStations replaces the stations table of the given schema with the
+// given stations
+func InsertStations(connectionString, schema string, stations []mosmix.Station) error {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", schema))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(createStationsTableStmt, schema))
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf("TRUNCATE %s.stations;", schema))
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyInSchema(schema, "stations", "id", "wmo_id", "icao", "name", "elevation", "the_geom"))
+	if err != nil {
+		return err
+	}
+
+	for _, station := range stations {
+		var wmoID, icao sql.NullString
+		if station.WMOID != "" {
+			wmoID = sql.NullString{String: station.WMOID, Valid: true}
+		}
+		if station.ICAO != "" {
+			icao = sql.NullString{String: station.ICAO, Valid: true}
+		}
+		// COPY takes the geometry as EWKT
+		geom := fmt.Sprintf("SRID=4326;POINT Z (%f %f %d)", station.Longitude, station.Latitude, station.Elevation)
+		_, err := stmt.Exec(station.ID, wmoID, icao, station.Name, station.Elevation, geom)
+		if err != nil {
+			return err
+		}
+	}
+	err = stmt.Close()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// StationByID looks up a station in the stations table
+func (m *MosmixDB) StationByID(id string) (mosmix.Station, error) {
+	station := mosmix.Station{ID: id}
+	var wmoID, icao sql.NullString
+	err := m.db.QueryRow(fmt.Sprintf(`SELECT wmo_id, icao, name, elevation, ST_X(the_geom), ST_Y(the_geom)
+		FROM %s.stations WHERE id = $1;`, m.schema), id).
+		Scan(&wmoID, &icao, &station.Name, &station.Elevation, &station.Longitude, &station.Latitude)
+	if err == sql.ErrNoRows {
+		return station, fmt.Errorf("station %s is missing from the stations table, load the catalog with mosmix-stations", id)
+	}
+	if err != nil {
+		return station, err
+	}
+	station.WMOID = wmoID.String
+	station.ICAO = icao.String
+
+	return station, nil
+}
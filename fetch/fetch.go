@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+)
+
+// Fetcher downloads the file at the url src into the local file dst
+type Fetcher interface {
+	Fetch(src, dst string) error
+}
+
+// Validators identify the version of a file on the server
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ConditionalFetcher is a Fetcher able to skip downloading unchanged files
+type ConditionalFetcher interface {
+	Fetcher
+	// FetchIfModified downloads src into dst unless the file on the server
+	// still matches the given validators. It returns whether the file has been
+	// downloaded and the validators of the file on the server
+	FetchIfModified(src, dst string, validators Validators) (bool, Validators, error)
+}
+
+// ForURL returns a Fetcher able to download the given url. Supported schemes
+// are http, https, file, s3+http and s3+https
+func ForURL(src string) (Fetcher, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPFetcher{http.DefaultClient}, nil
+	case "file":
+		return &FileFetcher{}, nil
+	case "s3+http", "s3+https":
+		return &S3Fetcher{}, nil
+	}
+
+	return nil, fmt.Errorf("No fetcher for url scheme %q", u.Scheme)
+}
+
+// Fetch downloads src into dst using the Fetcher for the scheme of src
+func Fetch(src, dst string) error {
+	fetcher, err := ForURL(src)
+	if err != nil {
+		return err
+	}
+	return fetcher.Fetch(src, dst)
+}
+
+// HTTPFetcher downloads files over http and https
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (h *HTTPFetcher) Fetch(src, dst string) error {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.download(req, dst)
+	return err
+}
+
+func (h *HTTPFetcher) FetchIfModified(src, dst string, validators Validators) (bool, Validators, error) {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return false, validators, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	resp, err := h.download(req, dst)
+	if err != nil {
+		return false, validators, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return false, validators, nil
+	}
+
+	return true, Validators{resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")}, nil
+}
+
+// download performs the request and writes the response body into dst. The
+// returned response has its body already consumed
+func (h *HTTPFetcher) download(req *http.Request, dst string) (*http.Response, error) {
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, retryable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{req.URL.String(), resp.StatusCode, resp.Status}
+	}
+
+	written, err := writeFile(dst, &retryableReader{resp.Body})
+	if err != nil {
+		return nil, err
+	}
+	// the size can only be checked when it is known, chunked and transparently
+	// decompressed responses have none
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return nil, retryable(fmt.Errorf("Downloading %s failed: received %d of %d bytes", req.URL, written, resp.ContentLength))
+	}
+
+	return resp, nil
+}
+
+// FileFetcher copies files from a local directory, the urls look like
+// file:///srv/mirror/MetElementDefinition.xml
+type FileFetcher struct{}
+
+func (f *FileFetcher) Fetch(src, dst string) error {
+	u, err := url.Parse(src)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(u.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = writeFile(dst, file)
+	return err
+}
+
+// S3Fetcher downloads files from S3 compatible buckets. The urls contain the
+// endpoint, the bucket and the key of the object, like
+// s3+https://minio.example.com:9000/bucket/weather/lib/MetElementDefinition.xml
+// Credentials are read from the usual AWS environment variables
+type S3Fetcher struct{}
+
+func (s *S3Fetcher) Fetch(src, dst string) error {
+	u, err := url.Parse(src)
+	if err != nil {
+		return err
+	}
+	u.Scheme = strings.TrimPrefix(u.Scheme, "s3+")
+
+	err = new(getter.S3Getter).GetFile(dst, u)
+	if s3Retryable(err) {
+		return retryable(err)
+	}
+	return err
+}
+
+// s3Retryable reports whether the S3 request failed on the transport, timed
+// out, was throttled or hit a server error. The errors of the AWS SDK are
+// matched by their methods, like awserr.RequestFailure
+func s3Retryable(err error) bool {
+	var failure interface{ StatusCode() int }
+	if errors.As(err, &failure) && retryableStatus(failure.StatusCode()) {
+		return true
+	}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		switch coded.Code() {
+		case "RequestError", "RequestTimeout", "ResponseTimeout", "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "RequestThrottled":
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func writeFile(dst string, r io.Reader) (int64, error) {
+	file, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return written, err
+	}
+
+	return written, file.Close()
+}
@@ -0,0 +1,162 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ChunkTiming reports how long the download of a byte range took
+type ChunkTiming struct {
+	Index    int
+	Start    int64
+	Length   int64
+	Duration time.Duration
+}
+
+// RangeFetcher downloads files over http and https by splitting them into
+// byte ranges, which are fetched over concurrent connections. It falls back
+// to a single stream when the HEAD request doesn't tell it can
+type RangeFetcher struct {
+	HTTPFetcher
+	Connections int
+	// OnChunk is called after every downloaded range when not nil
+	OnChunk func(ChunkTiming)
+}
+
+func (r *RangeFetcher) Fetch(src, dst string) error {
+	if r.Connections < 2 {
+		return r.HTTPFetcher.Fetch(src, dst)
+	}
+	size, lastModified, ok := r.probe(src)
+	if !ok {
+		return r.HTTPFetcher.Fetch(src, dst)
+	}
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	err = r.fetchRanges(src, lastModified, file, size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// probe returns the size and the Last-Modified header of the file when the
+// server accepts byte ranges for it. Failed HEAD requests, which some servers
+// deny with 403 or 405, are left to the single stream to report
+func (r *RangeFetcher) probe(src string) (int64, string, bool) {
+	resp, err := r.Client.Head(src)
+	if err != nil {
+		return 0, "", false
+	}
+	resp.Body.Close()
+
+	// the ranges are requested with If-Range, so they need the Last-Modified
+	// header to come from the same version of the file
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != 200 || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 || lastModified == "" {
+		return 0, "", false
+	}
+	return resp.ContentLength, lastModified, true
+}
+
+// fetchRanges downloads the file of the given size over concurrent range
+// requests into file
+func (r *RangeFetcher) fetchRanges(src, lastModified string, file *os.File, size int64) error {
+	err := file.Truncate(size)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := (size + int64(r.Connections) - 1) / int64(r.Connections)
+	var wg sync.WaitGroup
+	errs := make([]error, r.Connections)
+	for i := 0; i < r.Connections; i++ {
+		start := int64(i) * chunkSize
+		length := chunkSize
+		if start+length > size {
+			length = size - start
+		}
+		if length <= 0 {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, start, length int64) {
+			defer wg.Done()
+			startChunk := time.Now()
+			errs[i] = r.fetchRange(src, lastModified, file, start, length)
+			if errs[i] == nil && r.OnChunk != nil {
+				r.OnChunk(ChunkTiming{i, start, length, time.Now().Sub(startChunk)})
+			}
+		}(i, start, length)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return retryable(fmt.Errorf("Downloading %s failed: received %d of %d bytes", src, info.Size(), size))
+	}
+	return nil
+}
+
+// fetchRange downloads the byte range into the same range of the file
+func (r *RangeFetcher) fetchRange(src, lastModified string, file *os.File, start, length int64) error {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+length-1))
+	// the server answers with the complete file when it changed in between
+	req.Header.Set("If-Range", lastModified)
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return retryable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		return retryable(fmt.Errorf("Downloading %s failed: file changed during the download", src))
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return &StatusError{src, resp.StatusCode, resp.Status}
+	}
+
+	written, err := io.Copy(&offsetWriter{file, start}, &retryableReader{io.LimitReader(resp.Body, length)})
+	if err != nil {
+		return err
+	}
+	if written != length {
+		return retryable(fmt.Errorf("Downloading %s failed: received %d of %d bytes of range %d", src, written, length, start))
+	}
+
+	return nil
+}
+
+// offsetWriter writes sequentially into the file starting at offset
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.file.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRangeFetcher(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	modified := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		head       func(w http.ResponseWriter)
+		wantChunks int
+	}{
+		{"ranges", nil, 4},
+		{"HEAD not allowed", func(w http.ResponseWriter) { w.WriteHeader(http.StatusMethodNotAllowed) }, 0},
+		{"HEAD forbidden", func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) }, 0},
+		{"no Accept-Ranges", func(w http.ResponseWriter) {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", "10000")
+		}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "HEAD" && test.head != nil {
+					test.head(w)
+					return
+				}
+				http.ServeContent(w, r, "run.kmz", modified, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "mosmix")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			dst := filepath.Join(dir, "run.kmz")
+
+			var lock sync.Mutex
+			chunks := 0
+			fetcher := &RangeFetcher{HTTPFetcher: HTTPFetcher{server.Client()}, Connections: 4, OnChunk: func(ChunkTiming) {
+				lock.Lock()
+				chunks++
+				lock.Unlock()
+			}}
+			err = fetcher.Fetch(server.URL+"/run.kmz", dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("got %d bytes, want the %d bytes of the file", len(got), len(content))
+			}
+			if chunks != test.wantChunks {
+				t.Errorf("got %d ranges, want %d", chunks, test.wantChunks)
+			}
+		})
+	}
+}
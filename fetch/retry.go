@@ -0,0 +1,108 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how failed downloads are retried
+type RetryPolicy struct {
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries three times, starting with a backoff of one second
+var DefaultRetryPolicy = RetryPolicy{Retries: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// Retry calls fn until it succeeds, fails with an error which is not retryable
+// or the retries are exhausted. The backoff between the calls grows
+// exponentially and is jittered. It returns the number of retries
+func (p RetryPolicy) Retry(fn func() error) (int, error) {
+	backoff := p.Backoff
+	for retries := 0; ; retries++ {
+		err := fn()
+		if err == nil || !Retryable(err) || retries >= p.Retries {
+			return retries, err
+		}
+
+		// sleep somewhere between half and the whole backoff
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		fmt.Printf("%s, retrying in %s .... ", err, sleep)
+		time.Sleep(sleep)
+
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// StatusError is returned for unexpected http status codes
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("Downloading %s failed: %s", s.URL, s.Status)
+}
+
+// Retryable reports whether the server might still deliver the file
+func (s *StatusError) Retryable() bool {
+	return retryableStatus(s.StatusCode)
+}
+
+// retryableStatus reports whether a request failing with the http status code
+// might succeed later
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+type retryableError struct {
+	err error
+}
+
+func (r *retryableError) Error() string {
+	return r.err.Error()
+}
+
+func (r *retryableError) Retryable() bool {
+	return true
+}
+
+func (r *retryableError) Unwrap() error {
+	return r.err
+}
+
+func retryable(err error) error {
+	return &retryableError{err}
+}
+
+// Retryable classifies errors of the fetchers. Network errors, truncated
+// downloads and server errors are retryable, everything else is fatal
+func Retryable(err error) bool {
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+	return false
+}
+
+// retryableReader marks all read errors, except io.EOF, as retryable
+type retryableReader struct {
+	r io.Reader
+}
+
+func (r *retryableReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = retryable(err)
+	}
+	return n, err
+}
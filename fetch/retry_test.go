@@ -0,0 +1,173 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// awsError has the methods of awserr.RequestFailure
+type awsError struct {
+	code   string
+	status int
+}
+
+func (a awsError) Error() string     { return a.code }
+func (a awsError) Code() string      { return a.code }
+func (a awsError) Message() string   { return "" }
+func (a awsError) OrigErr() error    { return nil }
+func (a awsError) StatusCode() int   { return a.status }
+func (a awsError) RequestID() string { return "" }
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &StatusError{StatusCode: 503}, true},
+		{"too many requests", &StatusError{StatusCode: 429}, true},
+		{"not found", &StatusError{StatusCode: 404}, false},
+		{"wrapped server error", fmt.Errorf("run: %w", &StatusError{StatusCode: 500}), true},
+		{"truncated download", retryable(io.ErrUnexpectedEOF), true},
+		{"other error", errors.New("permission denied"), false},
+		{"no error", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Retryable(test.err); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestS3Retryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", awsError{"InternalError", 500}, true},
+		{"throttled", awsError{"SlowDown", 503}, true},
+		{"transport error", awsError{"RequestError", 0}, true},
+		{"missing key", awsError{"NoSuchKey", 404}, false},
+		{"access denied", awsError{"AccessDenied", 403}, false},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"file error", &os.PathError{Op: "open", Path: "/forecast.kmz", Err: os.ErrPermission}, false},
+		{"no error", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := s3Retryable(test.err); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{Retries: 3, Backoff: 20 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+
+	calls := 0
+	retries, err := policy.Retry(func() error {
+		calls++
+		if calls < 3 {
+			return &StatusError{StatusCode: 502, Status: "502 Bad Gateway"}
+		}
+		return nil
+	})
+	if err != nil || retries != 2 {
+		t.Errorf("got %d retries and error %v, want 2 retries", retries, err)
+	}
+
+	calls = 0
+	retries, err = policy.Retry(func() error {
+		calls++
+		return &StatusError{StatusCode: 404, Status: "404 Not Found"}
+	})
+	if err == nil || retries != 0 || calls != 1 {
+		t.Errorf("got %d retries and %d calls for a fatal error", retries, calls)
+	}
+
+	// the backoffs are jittered between half and the whole of 20ms, 40ms and 40ms
+	start := time.Now()
+	retries, err = policy.Retry(func() error {
+		return retryable(&StatusError{URL: "http://example.com/run.kmz", StatusCode: 503, Status: "503 Service Unavailable"})
+	})
+	elapsed := time.Since(start)
+	if retries != 3 {
+		t.Errorf("got %d retries, want 3", retries)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("retrying took %s, want between 50ms and 1s", elapsed)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+		t.Errorf("got error %v, want the StatusError", err)
+	}
+}
+
+// roundTripFunc answers requests without a server
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPFetcherContentLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantErr       string
+	}{
+		{"complete", "0123456789", 10, ""},
+		{"unknown length", "0123456789", -1, ""},
+		{"truncated", "01234", 10, "Downloading http://example.com/run.kmz failed: received 5 of 10 bytes"},
+	}
+
+	dir, err := ioutil.TempDir("", "mosmix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:        "200 OK",
+					StatusCode:    200,
+					Header:        http.Header{},
+					Body:          ioutil.NopCloser(strings.NewReader(test.body)),
+					ContentLength: test.contentLength,
+					Request:       req,
+				}, nil
+			})}
+			fetcher := &HTTPFetcher{client}
+
+			err := fetcher.Fetch("http://example.com/run.kmz", filepath.Join(dir, "run.kmz"))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr || !Retryable(err) {
+				t.Errorf("got error %v, want the retryable error %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+// Package gpkg writes the runs of the processor into a self-contained OGC
+// GeoPackage file, a SQLite database which QGIS, GDAL and friends read without
+// any server
+package gpkg
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+	// pure Go, so the static binaries of the scratch image keep working
+	_ "modernc.org/sqlite"
+)
+
+// the application_id and user_version pragmas of GeoPackage 1.2 files
+const (
+	applicationID = 0x47504B47
+	userVersion   = 10200
+)
+
+// srsID is the spatial reference system of the places, WGS 84
+const srsID = 4326
+
+// timestamps are stored as text in the format required by GeoPackage
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// GeoPackage is a Sink writing a run into a new GeoPackage file. Finalize
+// replaces the file at the path with it, so the file always holds the latest
+// finalized run
+type GeoPackage struct {
+	sync.Mutex
+	path                string
+	runPath             string
+	db                  *sql.DB
+	tx                  *sql.Tx
+	insertPlace         *sql.Stmt
+	insertForecast      *sql.Stmt
+	processingTimestamp time.Time
+	bounds              envelope
+}
+
+var _ mosmix.Sink = &GeoPackage{}
+
+// NewGeoPackage returns a Sink writing into the GeoPackage file at path. The
+// file is created by the first finalized run
+func NewGeoPackage(path string) (*GeoPackage, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return &GeoPackage{}, err
+	}
+	return &GeoPackage{path: absPath}, nil
+}
+
+// BeginRun creates the file of a new run next to the path of the GeoPackage
+func (g *GeoPackage) BeginRun() (time.Time, error) {
+	fmt.Print("Preparing GeoPackage ... ")
+	start := time.Now()
+
+	tmpfile, err := ioutil.TempFile(filepath.Dir(g.path), filepath.Base(g.path)+".tmp")
+	if err != nil {
+		return time.Time{}, err
+	}
+	tmpfile.Close()
+	g.runPath = tmpfile.Name()
+
+	g.db, err = sql.Open("sqlite", g.runPath)
+	if err != nil {
+		os.Remove(g.runPath)
+		return time.Time{}, err
+	}
+	// the pragmas and the transaction of the run need the same connection
+	g.db.SetMaxOpenConns(1)
+
+	g.processingTimestamp = time.Now()
+	g.bounds = newEnvelope()
+	err = g.createTables()
+	if err != nil {
+		g.Abort()
+		return time.Time{}, err
+	}
+	fmt.Printf("done in %s\n", time.Now().Sub(start))
+
+	return g.processingTimestamp, nil
+}
+
+func (g *GeoPackage) createTables() error {
+	_, err := g.db.Exec(fmt.Sprintf(`PRAGMA application_id = %d;
+	PRAGMA user_version = %d;
+	PRAGMA journal_mode = OFF;
+	PRAGMA synchronous = OFF;`, applicationID, userVersion))
+	if err != nil {
+		return err
+	}
+
+	g.tx, err = g.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = g.tx.Exec(createTablesStmt)
+	if err != nil {
+		return err
+	}
+
+	g.insertPlace, err = g.tx.Prepare(`INSERT INTO forecast_places (the_geom, id, name, processing_timestamp) VALUES (?, ?, ?, ?);`)
+	if err != nil {
+		return err
+	}
+	g.insertForecast, err = g.tx.Prepare(`INSERT INTO forecasts (place_id, name, timestep, value, processing_timestamp) VALUES (?, ?, ?, ?, ?);`)
+	return err
+}
+
+func (g *GeoPackage) InsertMetDefinitions(metDefinitions *[]mosmix.MetElement) error {
+	g.Lock()
+	defer g.Unlock()
+
+	stmt, err := g.tx.Prepare(`INSERT INTO met_element_definitions (short_name, unit_of_measurement, description, processing_timestamp) VALUES (?, ?, ?, ?);`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, metElement := range *metDefinitions {
+		_, err := stmt.Exec(metElement.ShortName, metElement.UnitOfMeasurement, metElement.Description, g.timestamp())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GeoPackage) InsertForecast(forecast *mosmix.ForecastPlace) error {
+	g.Lock()
+	defer g.Unlock()
+
+	_, err := g.insertPlace.Exec(pointGeometry(forecast.Geometry), forecast.ID, forecast.Name, g.timestamp())
+	if err != nil {
+		return err
+	}
+	g.bounds.extend(forecast.Geometry)
+
+	for _, variable := range forecast.ForecastVariables {
+		for _, value := range variable.Values {
+			_, err := g.insertForecast.Exec(forecast.ID, variable.Name, formatTime(value.Timestep), value.Value, g.timestamp())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *GeoPackage) InsertRejectedPlacemark(rejected *mosmix.RejectedPlacemark) error {
+	g.Lock()
+	defer g.Unlock()
+
+	line := sql.NullInt64{Int64: int64(rejected.Line), Valid: rejected.Line > 0}
+	id := sql.NullString{String: rejected.ID, Valid: rejected.ID != ""}
+
+	_, err := g.tx.Exec(`INSERT INTO rejected_placemarks (line, place_id, reason, processing_timestamp) VALUES (?, ?, ?, ?);`,
+		line, id, rejected.Reason, g.timestamp())
+	return err
+}
+
+type referencedModel struct {
+	Name          string `json:"name"`
+	ReferenceTime string `json:"reference_time"`
+}
+
+// InsertMetadata stores the metadata of the run. The arrays of the PostGIS
+// metadata table are stored as JSON arrays
+func (g *GeoPackage) InsertMetadata(metadata *mosmix.Metadata) error {
+	g.Lock()
+	defer g.Unlock()
+
+	var timesteps []string
+	for _, timestep := range metadata.ForecastTimeSteps {
+		timesteps = append(timesteps, formatTime(timestep))
+	}
+	var referencedModels []referencedModel
+	for _, model := range metadata.ReferencedModels {
+		referencedModels = append(referencedModels, referencedModel{model.Name, formatTime(model.ReferenceTime)})
+	}
+	issueTime := sql.NullString{String: formatTime(metadata.IssueTime.Time), Valid: metadata.IssueTime.Valid}
+
+	_, err := g.tx.Exec(`INSERT INTO metadata (
+		source_url,
+		processing_timestamp,
+		download_duration,
+		parsing_duration,
+		parser,
+		dwd_issuer,
+		dwd_product_id,
+		dwd_generating_process,
+		dwd_available_forecast_variables,
+		dwd_available_timesteps,
+		dwd_referenced_models,
+		definitions_source,
+		download_retries,
+		filter,
+		rejected_placemarks,
+		dwd_issue_time,
+		dwd_default_undef_sign,
+		dwd_format_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		metadata.SourceURL,
+		formatTime(metadata.ProcessingTime),
+		int64(metadata.DownloadDuration),
+		int64(metadata.ParsingDuration),
+		"github.com/codeformuenster/mosmix-processor",
+		metadata.Issuer,
+		metadata.ProductID,
+		metadata.GeneratingProcess,
+		jsonArray(metadata.AvailableVariables),
+		jsonArray(timesteps),
+		jsonArray(referencedModels),
+		metadata.DefinitionsSource,
+		metadata.DownloadRetries,
+		metadata.Filter,
+		metadata.RejectedPlacemarks,
+		issueTime,
+		metadata.DefaultUndefSign,
+		metadata.FormatVersion)
+	return err
+}
+
+// Finalize indexes the run and moves its file to the path of the GeoPackage
+func (g *GeoPackage) Finalize() error {
+	g.Lock()
+	defer g.Unlock()
+
+	_, err := g.tx.Exec(`UPDATE gpkg_contents SET last_change = ?;`, g.timestamp())
+	if err != nil {
+		return err
+	}
+	if !g.bounds.empty() {
+		_, err = g.tx.Exec(`UPDATE gpkg_contents SET min_x = ?, min_y = ?, max_x = ?, max_y = ?
+			WHERE table_name = 'forecast_places';`, g.bounds.minX, g.bounds.minY, g.bounds.maxX, g.bounds.maxY)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = g.tx.Exec(`CREATE INDEX idx_forecast_places_id ON forecast_places (id);
+	CREATE INDEX idx_forecasts_place_id_name ON forecasts (place_id, name);`)
+	if err != nil {
+		return err
+	}
+
+	err = g.tx.Commit()
+	g.tx = nil
+	if err != nil {
+		return err
+	}
+	err = g.db.Close()
+	g.db = nil
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(g.runPath, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(g.runPath, g.path)
+	if err != nil {
+		return err
+	}
+	g.runPath = ""
+
+	return nil
+}
+
+// Abort removes the file of the current run
+func (g *GeoPackage) Abort() error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.tx != nil {
+		g.tx.Rollback()
+		g.tx = nil
+	}
+	if g.db != nil {
+		g.db.Close()
+		g.db = nil
+	}
+	if g.runPath == "" {
+		return nil
+	}
+	err := os.Remove(g.runPath)
+	g.runPath = ""
+	return err
+}
+
+// Close removes the file of a run which has not been finalized
+func (g *GeoPackage) Close() error {
+	return g.Abort()
+}
+
+func (g *GeoPackage) timestamp() string {
+	return formatTime(g.processingTimestamp)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timestampFormat)
+}
+
+func jsonArray(v interface{}) string {
+	array, err := json.Marshal(v)
+	if err != nil || string(array) == "null" {
+		return "[]"
+	}
+	return string(array)
+}
+
+// pointGeometry encodes the point as GeoPackage geometry blob, the header
+// without envelope followed by the point as little endian ISO WKB PointZ
+func pointGeometry(point mosmix.KMLPoint) []byte {
+	var blob bytes.Buffer
+	// magic, version 1 and the flags for little endian without envelope
+	blob.Write([]byte{'G', 'P', 0, 1})
+	binary.Write(&blob, binary.LittleEndian, int32(srsID))
+	blob.WriteByte(1)
+	binary.Write(&blob, binary.LittleEndian, uint32(1001))
+	binary.Write(&blob, binary.LittleEndian, []float64{point.Longitude, point.Latitude, point.Altitude})
+	return blob.Bytes()
+}
+
+// envelope is the bounding box of the places of a run
+type envelope struct {
+	minX, minY, maxX, maxY float64
+}
+
+func newEnvelope() envelope {
+	return envelope{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (e *envelope) extend(point mosmix.KMLPoint) {
+	e.minX = math.Min(e.minX, point.Longitude)
+	e.minY = math.Min(e.minY, point.Latitude)
+	e.maxX = math.Max(e.maxX, point.Longitude)
+	e.maxY = math.Max(e.maxY, point.Latitude)
+}
+
+func (e *envelope) empty() bool {
+	return e.minX > e.maxX
+}
@@ -0,0 +1,111 @@
+package gpkg
+
+// createTablesStmt creates the core tables of the GeoPackage and the tables of
+// the run. They follow the PostGIS tables of the db package, forecast_places
+// is a PointZ feature table, the others are attribute tables. GeoPackage
+// requires an integer primary key for both
+const createTablesStmt = `CREATE TABLE gpkg_spatial_ref_sys (
+		srs_name TEXT NOT NULL,
+		srs_id INTEGER NOT NULL PRIMARY KEY,
+		organization TEXT NOT NULL,
+		organization_coordsys_id INTEGER NOT NULL,
+		definition TEXT NOT NULL,
+		description TEXT
+	);
+
+	INSERT INTO gpkg_spatial_ref_sys VALUES
+		('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+		('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+		('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AXIS["Latitude",NORTH],AXIS["Longitude",EAST],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid');
+
+	CREATE TABLE gpkg_contents (
+		table_name TEXT NOT NULL PRIMARY KEY,
+		data_type TEXT NOT NULL,
+		identifier TEXT UNIQUE,
+		description TEXT DEFAULT '',
+		last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+		min_x DOUBLE,
+		min_y DOUBLE,
+		max_x DOUBLE,
+		max_y DOUBLE,
+		srs_id INTEGER,
+		CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+	);
+
+	CREATE TABLE gpkg_geometry_columns (
+		table_name TEXT NOT NULL,
+		column_name TEXT NOT NULL,
+		geometry_type_name TEXT NOT NULL,
+		srs_id INTEGER NOT NULL,
+		z TINYINT NOT NULL,
+		m TINYINT NOT NULL,
+		CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+		CONSTRAINT uk_gc_table_name UNIQUE (table_name),
+		CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+		CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+	);
+
+	CREATE TABLE forecast_places (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		the_geom POINT NOT NULL,
+		id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		processing_timestamp DATETIME NOT NULL
+	);
+
+	CREATE TABLE forecasts (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		place_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		timestep DATETIME NOT NULL,
+		value DOUBLE NOT NULL,
+		processing_timestamp DATETIME NOT NULL
+	);
+
+	-- the arrays are JSON arrays, the durations nanoseconds
+	CREATE TABLE metadata (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_url TEXT NOT NULL,
+		processing_timestamp DATETIME NOT NULL,
+		download_duration INTEGER NOT NULL,
+		parsing_duration INTEGER NOT NULL,
+		parser TEXT NOT NULL,
+		dwd_issuer TEXT NOT NULL,
+		dwd_product_id TEXT NOT NULL,
+		dwd_generating_process TEXT NOT NULL,
+		dwd_available_forecast_variables TEXT NOT NULL,
+		dwd_available_timesteps TEXT NOT NULL,
+		dwd_referenced_models TEXT NOT NULL,
+		definitions_source TEXT NOT NULL,
+		download_retries INTEGER NOT NULL,
+		filter TEXT NOT NULL,
+		rejected_placemarks INTEGER NOT NULL,
+		dwd_issue_time DATETIME,
+		dwd_default_undef_sign TEXT,
+		dwd_format_version TEXT NOT NULL
+	);
+
+	CREATE TABLE met_element_definitions (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		description TEXT NOT NULL,
+		unit_of_measurement TEXT NOT NULL,
+		short_name TEXT NOT NULL,
+		processing_timestamp DATETIME NOT NULL
+	);
+
+	CREATE TABLE rejected_placemarks (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		line INTEGER,
+		place_id TEXT,
+		reason TEXT NOT NULL,
+		processing_timestamp DATETIME NOT NULL
+	);
+
+	INSERT INTO gpkg_contents (table_name, data_type, identifier, description, srs_id) VALUES
+		('forecast_places', 'features', 'forecast_places', 'the places of the forecasts', 4326),
+		('forecasts', 'attributes', 'forecasts', 'the forecast values of the places', NULL),
+		('metadata', 'attributes', 'metadata', 'the metadata of the run', NULL),
+		('met_element_definitions', 'attributes', 'met_element_definitions', 'the units and descriptions of the forecast elements', NULL),
+		('rejected_placemarks', 'attributes', 'rejected_placemarks', 'the Placemarks skipped in lenient mode', NULL);
+
+	INSERT INTO gpkg_geometry_columns VALUES ('forecast_places', 'the_geom', 'POINT', 4326, 1, 0);`
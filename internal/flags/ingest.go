@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/codeformuenster/mosmix-processor/fetch"
+	mosmixXML "github.com/codeformuenster/mosmix-processor/xml"
+)
+
+// Ingest holds the flags configuring how runs are downloaded and decoded
+type Ingest struct {
+	cacheDir     *string
+	retries      *int
+	retryBackoff *time.Duration
+	connections  *int
+	workers      *int
+	writers      *int
+	parser       *string
+	mode         *string
+}
+
+// RegisterIngest registers the download, parser and pipeline flags
+func RegisterIngest() *Ingest {
+	defaults := mosmixXML.DefaultOptions()
+	return &Ingest{
+		cacheDir:     flag.String("cache-dir", "", "directory to cache MetElementDefinition.xml in. Disabled when empty"),
+		retries:      flag.Int("retries", defaults.RetryPolicy.Retries, "how often failed downloads are retried"),
+		retryBackoff: flag.Duration("retry-backoff", defaults.RetryPolicy.Backoff, "the backoff before the first retry, doubled on every further retry"),
+		connections:  flag.Int("connections", defaults.Connections, "download files over http using this many concurrent range requests"),
+		workers:      flag.Int("workers", defaults.Workers, "number of goroutines splitting the forecast values"),
+		writers:      flag.Int("writers", defaults.Writers, "number of database connections inserting forecasts"),
+		parser:       flag.String("parser", defaults.Parser, "the KML parser, either \"std\" (encoding/xml), \"fast\" or \"conformance\" (both, failing when their output differs)"),
+		mode:         flag.String("mode", defaults.Mode, "how invalid Placemarks are handled, either \"strict\" (abort the run) or \"lenient\" (skip them and store them in the rejected_placemarks table)"),
+	}
+}
+
+// Options returns the options of the xml package for the parsed flags
+func (i *Ingest) Options() (mosmixXML.Options, error) {
+	options := mosmixXML.DefaultOptions()
+	if *i.mode != mosmixXML.ModeStrict && *i.mode != mosmixXML.ModeLenient {
+		return options, errors.New("Unknown mode, either \"strict\" or \"lenient\"")
+	}
+	options.DefinitionsCacheDir = *i.cacheDir
+	options.RetryPolicy.Retries = *i.retries
+	options.RetryPolicy.Backoff = *i.retryBackoff
+	options.Connections = *i.connections
+	options.Workers = *i.workers
+	options.Writers = *i.writers
+	options.Parser = *i.parser
+	options.Mode = *i.mode
+	options.OnChunk = func(chunk fetch.ChunkTiming) {
+		fmt.Printf("\n  range %d (%d bytes at %d) done in %s", chunk.Index, chunk.Length, chunk.Start, chunk.Duration)
+	}
+	return options, nil
+}
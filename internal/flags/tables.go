@@ -0,0 +1,73 @@
+// Package flags registers the command line flags shared by the commands
+// processing runs
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	mosmixDB "github.com/codeformuenster/mosmix-processor/db"
+)
+
+// Tables holds the flags configuring how the tables of the runs are added to
+// the schema, kept and archived
+type Tables struct {
+	partitioning *string
+	keepRuns     *int
+	keepFor      *time.Duration
+	keepDailyFor *time.Duration
+	archive      *bool
+	// RetentionDryRun only lists the runs the retention flags would drop
+	RetentionDryRun *bool
+}
+
+// RegisterTables registers the partitioning, retention and archive flags
+func RegisterTables() *Tables {
+	return &Tables{
+		partitioning:    flag.String("partitioning", mosmixDB.PartitioningInheritance, "how the tables of a run are added to the parent tables, either \"inheritance\" or \"declarative\" (PostgreSQL 12+ partitions). A schema can't switch without dropping its tables"),
+		keepRuns:        flag.Int("keep-runs", 1, "number of runs kept in the schema, including the processed one"),
+		keepFor:         flag.Duration("keep-for", 0, "keep the runs processed within this duration, like 48h"),
+		keepDailyFor:    flag.Duration("keep-daily-for", 0, "keep the first run issued on every UTC day within this duration, like 720h"),
+		RetentionDryRun: flag.Bool("retention-dry-run", false, "only list the runs the retention flags would drop when the next run is finalized, without processing a run"),
+		archive:         flag.Bool("archive", false, "append every run to the long-term archive in the schema with the suffix _archive"),
+	}
+}
+
+// Options returns the options of the db package for the parsed flags
+func (t *Tables) Options() (mosmixDB.Options, error) {
+	if *t.partitioning != mosmixDB.PartitioningInheritance && *t.partitioning != mosmixDB.PartitioningDeclarative {
+		return mosmixDB.Options{}, errors.New("Unknown partitioning, either \"inheritance\" or \"declarative\"")
+	}
+	return mosmixDB.Options{
+		Partitioning: *t.partitioning,
+		Retention: mosmixDB.RetentionPolicy{
+			KeepLast:     *t.keepRuns,
+			KeepFor:      *t.keepFor,
+			KeepDailyFor: *t.keepDailyFor,
+		},
+		Archive: *t.archive,
+	}, nil
+}
+
+// PrintDroppedRuns lists the runs the retention policy drops when the next run
+// is finalized
+func PrintDroppedRuns(dbPath, schema string, retention mosmixDB.RetentionPolicy) {
+	dropped, err := mosmixDB.RunsDroppedByRetention(dbPath, schema, retention)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Retention would drop %d runs of %s when the next run is finalized\n", len(dropped), schema)
+	for _, run := range dropped {
+		switch {
+		case !run.Finalized:
+			fmt.Printf("  run %s (not finalized)\n", run.Identifier)
+		case run.IssueTime.Valid:
+			fmt.Printf("  run %s (issued %s)\n", run.Identifier, run.IssueTime.Time.UTC().Format("2006-01-02 15Z"))
+		default:
+			fmt.Printf("  run %s\n", run.Identifier)
+		}
+	}
+}
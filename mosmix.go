@@ -1 +1,162 @@
+// Package mosmix holds the forecasts, stations and metadata of the mosmix
+// runs and the Sink interface of the storage backends. It depends on none of
+// them, so the decoders can be used without a database
 package mosmix
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StringArray []string
+
+type TimeArray []time.Time
+
+// NullTime is a time which may be missing, like the IssueTime of documents
+// leaving it empty
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *NullTime) UnmarshalText(text []byte) error {
+	text = bytes.TrimSpace(text)
+	if len(text) == 0 {
+		*t = NullTime{}
+		return nil
+	}
+	err := t.Time.UnmarshalText(text)
+	t.Valid = err == nil
+	return err
+}
+
+type ReferencedModel struct {
+	Name          string    `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd name,attr"`
+	ReferenceTime time.Time `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd referenceTime,attr"`
+}
+
+type ReferencedModels []ReferencedModel
+
+// where the element definitions of a run came from
+const (
+	DefinitionsSourceDownload = "download"
+	DefinitionsSourceCache    = "cache"
+	DefinitionsSourceFile     = "file"
+)
+
+type Metadata struct {
+	ForecastTimeSteps TimeArray        `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ForecastTimeSteps>TimeStep"`
+	DefaultUndefSign  string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd FormatCfg>DefaultUndefSign"`
+	GeneratingProcess string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd GeneratingProcess"`
+	Issuer            string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd Issuer"`
+	ProductID         string           `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ProductID"`
+	ReferencedModels  ReferencedModels `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd ReferencedModel>Model"`
+	IssueTime         NullTime         `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd IssueTime"`
+	// FormatVersion is the version of the pointforecast_dwd_extension format
+	// of the document, empty for other formats
+	FormatVersion      string
+	ProcessingTime     time.Time
+	DownloadDuration   time.Duration
+	ParsingDuration    time.Duration
+	SourceURL          string
+	AvailableVariables StringArray
+	DefinitionsSource  string
+	DownloadRetries    int
+	// Filter describes the places ingested, it is empty when the run holds
+	// all places of the source
+	Filter string
+	// RejectedPlacemarks is the number of Placemarks skipped in lenient mode
+	RejectedPlacemarks int
+}
+
+type ForecastVariable struct {
+	Name      string `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd elementName,attr"`
+	RawValues string `xml:"https://opendata.dwd.de/weather/lib/pointforecast_dwd_extension_V1_0.xsd value"`
+	Values    []ForecastVariableTimestep
+}
+
+type ForecastPlace struct {
+	ForecastVariables []ForecastVariable `xml:"ExtendedData>Forecast"` // Ignore namespace, because I don't know how to write this with namespace
+	Geometry          KMLPoint           `xml:"http://www.opengis.net/kml/2.2 Point>coordinates"`
+	Name              string             `xml:"http://www.opengis.net/kml/2.2 description"`
+	ID                string             `xml:"http://www.opengis.net/kml/2.2 name"`
+}
+
+type KMLPoint struct {
+	Longitude, Latitude, Altitude float64
+}
+
+type ForecastVariableTimestep struct {
+	Timestep time.Time
+	Value    float64
+}
+
+type MetElement struct {
+	ShortName         string `xml:"ShortName"`
+	UnitOfMeasurement string `xml:"UnitOfMeasurement"`
+	Description       string `xml:"Description"`
+}
+
+// RejectedPlacemark is a Placemark skipped in lenient mode
+type RejectedPlacemark struct {
+	// Line is the line of the start of the Placemark, 0 when unknown
+	Line int
+	// ID is the station id of the Placemark, empty when unknown
+	ID     string
+	Reason string
+}
+
+// Station is an entry of the mosmix station catalog
+type Station struct {
+	ID        string
+	WMOID     string
+	ICAO      string
+	Name      string
+	Latitude  float64
+	Longitude float64
+	Elevation int
+}
+
+func (k *KMLPoint) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var kmlPoint string
+
+	if err := d.DecodeElement(&kmlPoint, &start); err != nil {
+		return err
+	}
+
+	point, err := ParseKMLPoint(kmlPoint)
+	if err != nil {
+		return err
+	}
+	*k = point
+
+	return nil
+}
+
+// ErrInvalidCoordinates is returned by ParseKMLPoint for coordinates which
+// can't be parsed
+var ErrInvalidCoordinates = errors.New("invalid coordinates")
+
+// ParseKMLPoint parses KML coordinates like "7.7,52.13,48.0"
+func ParseKMLPoint(kmlPoint string) (KMLPoint, error) {
+	parts := strings.Split(kmlPoint, ",")
+	if len(parts) != 3 {
+		return KMLPoint{}, fmt.Errorf("%w %q: expected 3 parts, found %d", ErrInvalidCoordinates, kmlPoint, len(parts))
+	}
+
+	var values [3]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return KMLPoint{}, fmt.Errorf("%w %q: %q is not a number", ErrInvalidCoordinates, kmlPoint, part)
+		}
+		values[i] = value
+	}
+
+	return KMLPoint{values[0], values[1], values[2]}, nil
+}
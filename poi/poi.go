@@ -0,0 +1,159 @@
+package poi
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	mosmix "github.com/codeformuenster/mosmix-processor"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// UndefSign marks missing values in the POI files
+const UndefSign = "---"
+
+// Location is the time zone of the date and time columns
+var Location = time.UTC
+
+var datePattern = regexp.MustCompile(`^\d{2}\.\d{2}\.\d{2}$`)
+
+// Parse parses a DWD POI forecast, like P0036-MOSMIX.csv. The semicolon
+// separated file starts with several header lines, the first one naming the
+// elements, the others holding their german and english descriptions and
+// units. Every following line holds the date and time of a timestep and the
+// values with comma decimal separators.
+//
+// The returned place has neither ID, name nor geometry, as the file does not
+// contain them. The values are converted from the units of the POI file, read
+// from the last header line, into the units of the KML products, so they match
+// the element definitions of MetElementDefinition.xml
+func Parse(r io.Reader) (*mosmix.Metadata, *mosmix.ForecastPlace, error) {
+	// the files are latin1 encoded
+	reader := csv.NewReader(charmap.ISO8859_1.NewDecoder().Reader(r))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	metadata := &mosmix.Metadata{
+		Issuer:            "Deutscher Wetterdienst",
+		ProductID:         "POI",
+		GeneratingProcess: "MOSMIX POI",
+		DefaultUndefSign:  UndefSign,
+	}
+
+	var header [][]string
+	var columns [][]string
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !datePattern.MatchString(strings.TrimSpace(record[0])) {
+			if columns != nil {
+				return nil, nil, fmt.Errorf("line %d: unexpected header line after the values", line)
+			}
+			header = append(header, record)
+			continue
+		}
+		if len(header) == 0 {
+			return nil, nil, errors.New("missing header with the element names")
+		}
+		if len(record) != len(header[0]) {
+			return nil, nil, fmt.Errorf("line %d: expected %d columns, found %d", line, len(header[0]), len(record))
+		}
+
+		timestep, err := time.ParseInLocation("02.01.06 15:04", strings.TrimSpace(record[0])+" "+strings.TrimSpace(record[1]), Location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		metadata.ForecastTimeSteps = append(metadata.ForecastTimeSteps, timestep.UTC())
+
+		for ctColumn, value := range record[2:] {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				value = UndefSign
+			}
+			if ctColumn >= len(columns) {
+				columns = append(columns, nil)
+			}
+			columns[ctColumn] = append(columns[ctColumn], strings.Replace(value, ",", ".", 1))
+		}
+	}
+
+	if columns == nil {
+		return nil, nil, errors.New("no forecast values found")
+	}
+
+	place := &mosmix.ForecastPlace{}
+	for ctColumn, values := range columns {
+		name := strings.TrimSpace(header[0][ctColumn+2])
+		if len(header) > 1 {
+			units := header[len(header)-1]
+			if ctColumn+2 < len(units) {
+				convertValues(name, strings.TrimSpace(units[ctColumn+2]), values)
+			}
+		}
+		place.ForecastVariables = append(place.ForecastVariables, mosmix.ForecastVariable{
+			Name:      name,
+			RawValues: strings.Join(values, " "),
+		})
+	}
+
+	return metadata, place, nil
+}
+
+// conversion maps a value of a POI unit onto the unit of the KML products
+type conversion struct {
+	factor, offset float64
+}
+
+// conversions are the POI units which differ from the units of the KML
+// products. The other units, like %, mm or kJ/m2, are the same in both
+var conversions = map[string]conversion{
+	"Grad C": {1, 273.15},
+	"°C":     {1, 273.15},
+	"hPa":    {100, 0},
+	"km/h":   {1 / 3.6, 0},
+	"km":     {1000, 0},
+	"min":    {60, 0},
+	"h":      {3600, 0},
+}
+
+// convertValues converts the values of the element from the given POI unit
+// into the unit of the KML products. Undefined and invalid values are kept, so
+// the invalid ones are reported when they are split
+func convertValues(name, unit string, values []string) {
+	conversion, ok := conversions[unit]
+	if !ok {
+		return
+	}
+	// the deviations, like E_TTT, are temperature differences
+	if strings.HasPrefix(name, "E_") {
+		conversion.offset = 0
+	}
+
+	for i, value := range values {
+		if value == UndefSign {
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		// keep the conversion from adding float noise like 291.24999999999997
+		number = math.Round((number*conversion.factor+conversion.offset)*1e6) / 1e6
+		values[i] = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+}
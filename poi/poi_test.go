@@ -0,0 +1,98 @@
+package poi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHeader = "forecast;;PPPP;E_PPP;TTT;E_TTT;FF;VV;SunD1;RR1c;N\n" +
+	"parameter;;Druck;Abweichung Druck;Temperatur;Abweichung Temperatur;Wind;Sicht;Sonnenschein;Niederschlag;Bewoelkung\n" +
+	"dd.mm.yy;hh:mm;hPa;hPa;Grad C;Grad C;km/h;km;min;mm;%\n"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		timesteps []time.Time
+		values    map[string]string
+		err       string
+	}{
+		{
+			name:      "converts into the KML units",
+			input:     testHeader + "18.10.26;06:00;1018,5;0,4;18,1;-1,5;36;12,5;30;0,1;75\n",
+			timesteps: []time.Time{time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)},
+			values: map[string]string{
+				"PPPP": "101850", "E_PPP": "40", "TTT": "291.25", "E_TTT": "-1.5",
+				"FF": "10", "VV": "12500", "SunD1": "1800", "RR1c": "0.1", "N": "75",
+			},
+		},
+		{
+			name: "keeps undefined values",
+			input: testHeader +
+				"18.10.26;06:00;---;---;---;;---;---;---;---;---\n" +
+				"18.10.26;07:00;1000;0;0;0;0;0;0;0;0\n",
+			timesteps: []time.Time{
+				time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC),
+			},
+			values: map[string]string{
+				"PPPP": "--- 100000", "E_PPP": "--- 0", "TTT": "--- 273.15", "E_TTT": "--- 0",
+				"FF": "--- 0", "VV": "--- 0", "SunD1": "--- 0", "RR1c": "--- 0", "N": "--- 0",
+			},
+		},
+		{
+			name:      "without unit line",
+			input:     "forecast;;TTT\n18.10.26;06:00;18,1\n",
+			timesteps: []time.Time{time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)},
+			values:    map[string]string{"TTT": "18.1"},
+		},
+		{
+			name:  "missing header",
+			input: "18.10.26;06:00;18,1\n",
+			err:   "missing header with the element names",
+		},
+		{
+			name:  "wrong number of columns",
+			input: testHeader + "18.10.26;06:00;1018,5\n",
+			err:   "line 4: expected 11 columns, found 3",
+		},
+		{
+			name:  "header after the values",
+			input: testHeader + "18.10.26;06:00;1018,5;0,4;18,1;-1,5;36;12,5;30;0,1;75\nforecast;;PPPP\n",
+			err:   "line 5: unexpected header line after the values",
+		},
+		{
+			name:  "no values",
+			input: testHeader,
+			err:   "no forecast values found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, place, err := Parse(strings.NewReader(test.input))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := []time.Time(metadata.ForecastTimeSteps); !reflect.DeepEqual(got, test.timesteps) {
+				t.Errorf("got timesteps %v, want %v", got, test.timesteps)
+			}
+			values := make(map[string]string)
+			for _, variable := range place.ForecastVariables {
+				values[variable.Name] = variable.RawValues
+			}
+			if !reflect.DeepEqual(values, test.values) {
+				t.Errorf("got values %v, want %v", values, test.values)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package mosmix
+
+import "time"
+
+// Sink stores the runs of the processor. The db package writes them into
+// PostGIS, the gpkg package into GeoPackage files. Every run starts with
+// BeginRun, followed by the inserts and ends with either Finalize or Abort.
+// InsertForecast and InsertRejectedPlacemark are called concurrently
+type Sink interface {
+	// BeginRun starts a new run and returns its processing timestamp, which
+	// identifies the run
+	BeginRun() (time.Time, error)
+	InsertMetDefinitions(metDefinitions *[]MetElement) error
+	InsertForecast(forecast *ForecastPlace) error
+	InsertRejectedPlacemark(rejected *RejectedPlacemark) error
+	InsertMetadata(metadata *Metadata) error
+	// Finalize makes the current run available to the readers of the sink
+	Finalize() error
+	// Abort discards the current run after a failure
+	Abort() error
+	Close() error
+}
+
+// StationFinder is implemented by the sinks knowing the station catalog
+type StationFinder interface {
+	StationByID(id string) (Station, error)
+}
+
+// RunKeeper is implemented by the sinks which can keep older runs besides the
+// latest one
+type RunKeeper interface {
+	// KeepsRuns reports whether the runs of the given issue times, finalized
+	// in a row, are all kept. The zero time stands for an unknown issue time
+	KeepsRuns(issueTimes []time.Time) bool
+}
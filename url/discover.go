@@ -0,0 +1,148 @@
+package url
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AvailableRun is a run of a mosmix product listed on the DWD server
+type AvailableRun struct {
+	URL          string
+	Run          time.Time
+	Size         int64
+	LastModified time.Time
+}
+
+var runFilePatterns = map[string]*regexp.Regexp{
+	"mosmix_s": regexp.MustCompile(`^MOSMIX_S_(\d{10})_240\.kmz$`),
+	"mosmix_l": regexp.MustCompile(`^MOSMIX_L_(\d{10})\.kmz$`),
+}
+
+// matches the entries of nginx and apache style directory indexes, like
+// <a href="MOSMIX_L_2018061003.kmz">MOSMIX_L_2018061003.kmz</a>   10-Jun-2018 05:21   41234567
+var listingEntryPattern = regexp.MustCompile(`<a href="([^"]+)">[^<]*</a>\s+(\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}|\d{4}-\d{2}-\d{2} \d{2}:\d{2})\s+(\d+|-)`)
+
+var listingTimeLayouts = []string{"02-Jan-2006 15:04", "2006-01-02 15:04"}
+
+// Directory returns the URL of the directory holding the runs of the given
+// mosmix schema
+func Directory(schema string) (string, error) {
+	if schema == "mosmix_s" {
+		return fmt.Sprintf("%s/MOSMIX_S/all_stations/kml/", BaseURL), nil
+	} else if schema == "mosmix_l" {
+		return fmt.Sprintf("%s/MOSMIX_L/all_stations/kml/", BaseURL), nil
+	}
+
+	return "", errors.New("Unknown schema")
+}
+
+// Discover lists the runs of the given mosmix schema available on the DWD
+// server, sorted by their issue time
+func Discover(schema string) ([]AvailableRun, error) {
+	directoryURL, err := Directory(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(directoryURL, "file://") {
+		return listDirectory(directoryURL, runFilePatterns[schema])
+	}
+
+	resp, err := http.Get(directoryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Listing %s failed: %s", directoryURL, resp.Status)
+	}
+
+	return parseListing(resp.Body, directoryURL, runFilePatterns[schema])
+}
+
+// listDirectory lists the runs in a local mirror directory
+func listDirectory(directoryURL string, filePattern *regexp.Regexp) ([]AvailableRun, error) {
+	files, err := ioutil.ReadDir(strings.TrimPrefix(directoryURL, "file://"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []AvailableRun
+	for _, file := range files {
+		parts := filePattern.FindStringSubmatch(file.Name())
+		if parts == nil || file.IsDir() {
+			continue
+		}
+
+		run, err := time.Parse("2006010215", parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, AvailableRun{directoryURL + file.Name(), run, file.Size(), file.ModTime().UTC()})
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Run.Before(runs[j].Run)
+	})
+
+	return runs, nil
+}
+
+func parseListing(body io.Reader, directoryURL string, filePattern *regexp.Regexp) ([]AvailableRun, error) {
+	listing, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []AvailableRun
+	for _, entry := range listingEntryPattern.FindAllSubmatch(listing, -1) {
+		filename := string(entry[1])
+		parts := filePattern.FindStringSubmatch(filename)
+		if parts == nil {
+			continue
+		}
+
+		run, err := time.Parse("2006010215", parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		var lastModified time.Time
+		for _, layout := range listingTimeLayouts {
+			lastModified, err = time.Parse(layout, string(entry[2]))
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// directories and unknown sizes are listed as -
+		var size int64 = -1
+		if string(entry[3]) != "-" {
+			size, err = strconv.ParseInt(string(entry[3]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		runs = append(runs, AvailableRun{directoryURL + filename, run, size, lastModified})
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Run.Before(runs[j].Run)
+	})
+
+	return runs, nil
+}
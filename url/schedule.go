@@ -0,0 +1,85 @@
+package url
+
+import (
+	"errors"
+	"time"
+)
+
+// Product describes when DWD issues a mosmix product
+type Product struct {
+	// IssueHours are the UTC hours of the day a run is issued
+	IssueHours []int
+	// Delay is the typical time between the issue time of a run and the
+	// moment its file is published on opendata.dwd.de
+	Delay time.Duration
+}
+
+// Products maps the mosmix schema names to their issue schedules
+var Products = map[string]Product{
+	"mosmix_s": {
+		IssueHours: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23},
+		Delay:      30 * time.Minute,
+	},
+	"mosmix_l": {
+		IssueHours: []int{3, 9, 15, 21},
+		Delay:      2 * time.Hour,
+	},
+}
+
+// RunCalculator computes the issue times of mosmix runs
+type RunCalculator struct {
+	Product Product
+	// Now returns the current time, replace it to inject a clock
+	Now func() time.Time
+}
+
+// NewRunCalculator returns a RunCalculator for the given mosmix schema using
+// the system clock
+func NewRunCalculator(schema string) (*RunCalculator, error) {
+	product, ok := Products[schema]
+	if !ok {
+		return nil, errors.New("Unknown schema")
+	}
+	return &RunCalculator{product, time.Now}, nil
+}
+
+// RunAt returns the issue time of the last run issued at or before t
+func (r *RunCalculator) RunAt(t time.Time) time.Time {
+	t = t.UTC()
+	day := t.Truncate(24 * time.Hour)
+	// walk backwards through the issue hours of today and yesterday
+	for ctDay := 0; ctDay < 2; ctDay++ {
+		for i := len(r.Product.IssueHours) - 1; i >= 0; i-- {
+			run := day.Add(time.Duration(r.Product.IssueHours[i]) * time.Hour)
+			if !run.After(t) {
+				return run
+			}
+		}
+		day = day.Add(-24 * time.Hour)
+	}
+	return time.Time{}
+}
+
+// Latest returns the issue time of the latest run expected to be available now
+func (r *RunCalculator) Latest() time.Time {
+	return r.RunAt(r.Now().Add(-r.Product.Delay))
+}
+
+// Next returns the issue time of the first run after the latest available one
+func (r *RunCalculator) Next() time.Time {
+	latest := r.Latest()
+	// the next run is issued at most one day after the latest
+	for _, hour := range r.Product.IssueHours {
+		run := latest.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour)
+		if run.After(latest) {
+			return run
+		}
+	}
+	return latest.Truncate(24 * time.Hour).Add(time.Duration(24+r.Product.IssueHours[0]) * time.Hour)
+}
+
+// Available returns when the file of the run issued at run is expected to be
+// published
+func (r *RunCalculator) Available(run time.Time) time.Time {
+	return run.Add(r.Product.Delay)
+}
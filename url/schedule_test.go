@@ -0,0 +1,77 @@
+package url
+
+import (
+	"testing"
+	"time"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestRunCalculator(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		now    time.Time
+		latest time.Time
+		next   time.Time
+	}{
+		{"MOSMIX_L published", "mosmix_l", at(18, 11, 0), at(18, 9, 0), at(18, 15, 0)},
+		{"MOSMIX_L not yet published", "mosmix_l", at(18, 10, 59), at(18, 3, 0), at(18, 9, 0)},
+		{"MOSMIX_L last run of the day", "mosmix_l", at(18, 23, 30), at(18, 21, 0), at(19, 3, 0)},
+		{"MOSMIX_L run of yesterday", "mosmix_l", at(18, 4, 0), at(17, 21, 0), at(18, 3, 0)},
+		{"MOSMIX_L issued but not yet published", "mosmix_l", at(17, 22, 59), at(17, 15, 0), at(17, 21, 0)},
+		{"MOSMIX_S published", "mosmix_s", at(18, 12, 30), at(18, 12, 0), at(18, 13, 0)},
+		{"MOSMIX_S not yet published", "mosmix_s", at(18, 12, 29), at(18, 11, 0), at(18, 12, 0)},
+		{"MOSMIX_S across midnight", "mosmix_s", at(18, 0, 15), at(17, 23, 0), at(18, 0, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calculator, err := NewRunCalculator(test.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calculator.Now = func() time.Time { return test.now }
+
+			if got := calculator.Latest(); !got.Equal(test.latest) {
+				t.Errorf("got latest run %v, want %v", got, test.latest)
+			}
+			if got := calculator.Next(); !got.Equal(test.next) {
+				t.Errorf("got next run %v, want %v", got, test.next)
+			}
+		})
+	}
+}
+
+func TestRunAt(t *testing.T) {
+	calculator, err := NewRunCalculator("mosmix_l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin := time.FixedZone("CEST", 2*60*60)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"at the issue time", at(18, 9, 0), at(18, 9, 0)},
+		{"between the issue times", at(18, 14, 59), at(18, 9, 0)},
+		{"before the first issue time", at(18, 2, 59), at(17, 21, 0)},
+		{"other time zone", time.Date(2026, 10, 18, 4, 59, 0, 0, berlin), at(17, 21, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := calculator.RunAt(test.t); !got.Equal(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if _, err := NewRunCalculator("mosmix_x"); err == nil {
+		t.Error("expected an error for an unknown schema")
+	}
+}
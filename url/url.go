@@ -6,18 +6,70 @@ import (
 	"time"
 )
 
-const baseURL = "https://opendata.dwd.de/weather/local_forecasts/mos"
+// BaseURL is the url of the mosmix directory, change it to use a mirror
+var BaseURL = "https://opendata.dwd.de/weather/local_forecasts/mos"
 
-// Generate can be used to to generate a valid mosmix URL
+// POIBaseURL is the url of the POI directory, change it to use a mirror
+var POIBaseURL = "https://opendata.dwd.de/weather/local_forecasts/poi"
+
+// Generate can be used to to generate a valid mosmix URL for the latest run
+// expected to be available
 func Generate(schema string) (string, error) {
+	calculator, err := NewRunCalculator(schema)
+	if err != nil {
+		return "", err
+	}
+
+	return GenerateForRun(schema, calculator.Latest())
+}
+
+// GenerateForRun generates the mosmix URL of the run issued at the given time
+func GenerateForRun(schema string, run time.Time) (string, error) {
 	// create a timestamp like its used in the mosmix filename
-	timestamp := time.Now().UTC().Format("2006010215")
+	timestamp := run.UTC().Format("2006010215")
+
+	directoryURL, err := Directory(schema)
+	if err != nil {
+		return "", err
+	}
 
 	if schema == "mosmix_s" {
-		return fmt.Sprintf("%s/MOSMIX_S/all_stations/kml/MOSMIX_S_%s_240.kmz", baseURL, timestamp), nil
-	} else if schema == "mosmix_l" {
-		return fmt.Sprintf("%s/MOSMIX_L/all_stations/kml/MOSMIX_L_%s.kmz", baseURL, timestamp), nil
+		return fmt.Sprintf("%sMOSMIX_S_%s_240.kmz", directoryURL, timestamp), nil
+	}
+	return fmt.Sprintf("%sMOSMIX_L_%s.kmz", directoryURL, timestamp), nil
+}
+
+// GenerateStations generates the URLs of the latest single station files of
+// the given stations. DWD only publishes single station files for mosmix_l
+func GenerateStations(schema string, stationIDs []string) ([]string, error) {
+	return generateStations(schema, stationIDs, "LATEST")
+}
+
+// GenerateStationsForRun generates the URLs of the single station files of the
+// run issued at the given time. DWD keeps them for the last few runs only
+func GenerateStationsForRun(schema string, stationIDs []string, run time.Time) ([]string, error) {
+	return generateStations(schema, stationIDs, run.UTC().Format("2006010215"))
+}
+
+func generateStations(schema string, stationIDs []string, timestamp string) ([]string, error) {
+	if schema != "mosmix_l" {
+		return nil, errors.New("Single station files are only available for mosmix_l")
+	}
+
+	var urls []string
+	for _, stationID := range stationIDs {
+		urls = append(urls, fmt.Sprintf("%s/MOSMIX_L/single_stations/%[2]s/kml/MOSMIX_L_%[3]s_%[2]s.kmz", BaseURL, stationID, timestamp))
+	}
+
+	return urls, nil
+}
+
+// GeneratePOI generates the URLs of the POI csv files of the given stations
+func GeneratePOI(stationIDs []string) []string {
+	var urls []string
+	for _, stationID := range stationIDs {
+		urls = append(urls, fmt.Sprintf("%s/%s-MOSMIX.csv", POIBaseURL, stationID))
 	}
 
-	return "", errors.New("Unknown schema")
+	return urls
 }
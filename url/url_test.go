@@ -0,0 +1,33 @@
+package url
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateStations(t *testing.T) {
+	latest, err := GenerateStations("mosmix_l", []string{"10315", "P0036"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		BaseURL + "/MOSMIX_L/single_stations/10315/kml/MOSMIX_L_LATEST_10315.kmz",
+		BaseURL + "/MOSMIX_L/single_stations/P0036/kml/MOSMIX_L_LATEST_P0036.kmz",
+	}
+	if !reflect.DeepEqual(latest, want) {
+		t.Errorf("got %v, want %v", latest, want)
+	}
+
+	run, err := GenerateStationsForRun("mosmix_l", []string{"10315"}, at(18, 9, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{BaseURL + "/MOSMIX_L/single_stations/10315/kml/MOSMIX_L_2026101809_10315.kmz"}
+	if !reflect.DeepEqual(run, want) {
+		t.Errorf("got %v, want %v", run, want)
+	}
+
+	if _, err := GenerateStationsForRun("mosmix_s", []string{"10315"}, at(18, 9, 0)); err == nil {
+		t.Error("got single station files for mosmix_s")
+	}
+}